@@ -0,0 +1,165 @@
+// Package calendar knows the days on which NBP publishes the currency tables.
+// NBP publishes the tables on business days, i.e. on every day except weekends and Polish public holidays.
+package calendar
+
+import "time"
+
+// fixedHoliday is a public holiday falling on the same day every year, or a one-off day off.
+type fixedHoliday struct {
+	month time.Month
+	day   int
+	name  string
+	// since is the first year when the day was a public holiday.
+	since int
+	// until is the last year when the day was a public holiday, 0 if it still is.
+	until int
+}
+
+var fixedHolidays = []fixedHoliday{
+	{time.January, 1, "Nowy Rok", 0, 0},
+	{time.January, 6, "Święto Trzech Króli", 2011, 0},
+	{time.May, 1, "Święto Pracy", 0, 0},
+	{time.May, 3, "Święto Konstytucji 3 Maja", 0, 0},
+	{time.August, 15, "Wniebowzięcie Najświętszej Maryi Panny", 0, 0},
+	{time.November, 1, "Wszystkich Świętych", 0, 0},
+	{time.November, 11, "Narodowe Święto Niepodległości", 0, 0},
+	// A one-off day off for the 100th anniversary of independence, NBP didn't publish
+	{time.November, 12, "100. rocznica odzyskania niepodległości", 2018, 2018},
+	{time.December, 24, "Wigilia Bożego Narodzenia", 2025, 0},
+	{time.December, 25, "Boże Narodzenie", 0, 0},
+	{time.December, 26, "Drugi dzień Bożego Narodzenia", 0, 0},
+}
+
+// movableHolidays are the public holidays given as the number of days after Easter Sunday.
+var movableHolidays = []struct {
+	days int
+	name string
+}{
+	{0, "Wielkanoc"},
+	{1, "Poniedziałek Wielkanocny"},
+	{49, "Zielone Świątki"},
+	{60, "Boże Ciało"},
+}
+
+// Easter returns the date of Easter Sunday in the given year of the Gregorian calendar.
+func Easter(year int) time.Time {
+	// Anonymous Gregorian algorithm (Meeus/Jones/Butcher)
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Holiday returns the name of the Polish public holiday falling on the date.
+// It returns an empty string if the date is not a public holiday.
+func Holiday(t time.Time) string {
+	year, month, day := t.Date()
+	for _, h := range fixedHolidays {
+		if h.month == month && h.day == day && year >= h.since && (h.until == 0 || year <= h.until) {
+			return h.name
+		}
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	easter := Easter(year)
+	for _, h := range movableHolidays {
+		if easter.AddDate(0, 0, h.days).Equal(date) {
+			return h.name
+		}
+	}
+	return ""
+}
+
+// Day is a public holiday.
+type Day struct {
+	Date time.Time
+	Name string
+}
+
+// Holidays returns the Polish public holidays in the year, ordered by date.
+func Holidays(year int) []Day {
+	var res []Day
+	for d := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if h := Holiday(d); h != "" {
+			res = append(res, Day{Date: d, Name: h})
+		}
+	}
+	return res
+}
+
+// IsWeekend reports whether the date is Saturday or Sunday.
+func IsWeekend(t time.Time) bool {
+	wd := t.Weekday()
+	return wd == time.Saturday || wd == time.Sunday
+}
+
+// IsPublicationDay reports whether NBP publishes the tables on the date.
+func IsPublicationDay(t time.Time) bool {
+	return !IsWeekend(t) && Holiday(t) == ""
+}
+
+// Reason explains why no table is published on the date, e.g. "weekend" or "holiday (Boże Narodzenie)".
+// It returns an empty string for publication days.
+func Reason(t time.Time) string {
+	if h := Holiday(t); h != "" {
+		return "holiday (" + h + ")"
+	}
+	if IsWeekend(t) {
+		return "weekend"
+	}
+	return ""
+}
+
+// PreviousPublicationDay returns the last publication day before the date.
+func PreviousPublicationDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, -1)
+	for !IsPublicationDay(t) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// NextPublicationDay returns the first publication day after the date.
+func NextPublicationDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, 1)
+	for !IsPublicationDay(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// Mismatch is a date on which the calendar doesn't agree with the dates the tables were actually published on.
+type Mismatch struct {
+	Date time.Time
+	// Published tells if a table was published on the date, although the calendar doesn't predict it.
+	Published bool
+}
+
+// Check compares the calendar with the dates the tables were published on between from and to (inclusive).
+// The dates are usually taken from the NBP dir index, see svc.GetIndex.
+func Check(published []time.Time, from time.Time, to time.Time) []Mismatch {
+	days := map[string]bool{}
+	for _, d := range published {
+		days[d.Format("2006-01-02")] = true
+	}
+
+	var res []Mismatch
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		pub := days[d.Format("2006-01-02")]
+		if pub != IsPublicationDay(d) {
+			res = append(res, Mismatch{Date: d, Published: pub})
+		}
+	}
+	return res
+}
@@ -0,0 +1,144 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEaster(t *testing.T) {
+	tests := []struct {
+		year int
+		want string
+	}{
+		{2000, "2000-04-23"},
+		{2002, "2002-03-31"},
+		{2008, "2008-03-23"},
+		{2011, "2011-04-24"},
+		{2015, "2015-04-05"},
+		{2016, "2016-03-27"},
+		{2019, "2019-04-21"},
+		{2024, "2024-03-31"},
+		{2025, "2025-04-20"},
+		{2038, "2038-04-25"},
+	}
+	for _, tt := range tests {
+		if got := Easter(tt.year); !got.Equal(date(tt.want)) {
+			t.Errorf("Easter(%d) = %s, want %s", tt.year, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestHoliday(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2015-01-01", "Nowy Rok"},
+		{"2010-01-06", ""},
+		{"2011-01-06", "Święto Trzech Króli"},
+		{"2015-04-05", "Wielkanoc"},
+		{"2015-04-06", "Poniedziałek Wielkanocny"},
+		{"2015-05-24", "Zielone Świątki"},
+		{"2015-06-04", "Boże Ciało"},
+		{"2015-05-01", "Święto Pracy"},
+		{"2015-05-03", "Święto Konstytucji 3 Maja"},
+		{"2015-08-15", "Wniebowzięcie Najświętszej Maryi Panny"},
+		{"2015-11-01", "Wszystkich Świętych"},
+		{"2015-11-11", "Narodowe Święto Niepodległości"},
+		// The day off for the 100th anniversary was once
+		{"2018-11-12", "100. rocznica odzyskania niepodległości"},
+		{"2019-11-12", ""},
+		// Wigilia is a public holiday since 2025
+		{"2024-12-24", ""},
+		{"2025-12-24", "Wigilia Bożego Narodzenia"},
+		{"2025-12-25", "Boże Narodzenie"},
+		{"2025-12-26", "Drugi dzień Bożego Narodzenia"},
+		{"2015-11-25", ""},
+		{"2015-06-05", ""},
+	}
+	for _, tt := range tests {
+		if got := Holiday(date(tt.date)); got != tt.want {
+			t.Errorf("Holiday(%s) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestIsPublicationDay(t *testing.T) {
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2015-11-25", true},
+		{"2015-11-28", false}, // Saturday
+		{"2015-11-29", false}, // Sunday
+		{"2015-11-11", false},
+		{"2016-03-28", false}, // Easter Monday
+		{"2024-12-24", true},
+		{"2025-12-24", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicationDay(date(tt.date)); got != tt.want {
+			t.Errorf("IsPublicationDay(%s) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestReason(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2015-11-25", ""},
+		{"2015-11-28", "weekend"},
+		// A holiday on a weekend is reported as the holiday
+		{"2015-11-01", "holiday (Wszystkich Świętych)"},
+		{"2025-12-24", "holiday (Wigilia Bożego Narodzenia)"},
+	}
+	for _, tt := range tests {
+		if got := Reason(date(tt.date)); got != tt.want {
+			t.Errorf("Reason(%s) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestPublicationDays(t *testing.T) {
+	tests := []struct {
+		date, previous, next string
+	}{
+		{"2015-11-25", "2015-11-24", "2015-11-26"},
+		{"2015-11-30", "2015-11-27", "2015-12-01"},
+		// Easter weekend with Easter Monday
+		{"2016-03-26", "2016-03-25", "2016-03-29"},
+		// Wigilia joins Christmas since 2025
+		{"2024-12-25", "2024-12-24", "2024-12-27"},
+		{"2025-12-25", "2025-12-23", "2025-12-29"},
+		{"2016-01-01", "2015-12-31", "2016-01-04"},
+		{"2018-11-11", "2018-11-09", "2018-11-13"},
+	}
+	for _, tt := range tests {
+		if got := PreviousPublicationDay(date(tt.date)); !got.Equal(date(tt.previous)) {
+			t.Errorf("PreviousPublicationDay(%s) = %s, want %s", tt.date, got.Format("2006-01-02"), tt.previous)
+		}
+		if got := NextPublicationDay(date(tt.date)); !got.Equal(date(tt.next)) {
+			t.Errorf("NextPublicationDay(%s) = %s, want %s", tt.date, got.Format("2006-01-02"), tt.next)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// NBP published on 2015-11-27 as expected, skipped 2015-11-26 and published on the Saturday
+	published := []time.Time{date("2015-11-25"), date("2015-11-27"), date("2015-11-28"), date("2015-11-30")}
+	got := Check(published, date("2015-11-25"), date("2015-11-30"))
+	want := []Mismatch{
+		{Date: date("2015-11-26"), Published: false},
+		{Date: date("2015-11-28"), Published: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Check = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].Date.Equal(want[i].Date) || got[i].Published != want[i].Published {
+			t.Errorf("Check[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
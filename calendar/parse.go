@@ -0,0 +1,100 @@
+package calendar
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ErrBadDate is returned by ParseDate when the date is in none of the formats it accepts.
+var ErrBadDate = errors.New("Date should be like 2015-11-25, 25.11.2015, 2015-W48-3, 2015-11, today, yesterday, last-business-day or -3d")
+
+var (
+	isoWeek  = regexp.MustCompile(`^(\d{4})-W(\d{2})-([1-7])$`)
+	month    = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	relative = regexp.MustCompile(`^-(\d{1,5})d$`)
+)
+
+// ParseDate parses the date given in one of the formats:
+//
+//	2015-11-25          the date
+//	25.11.2015          the date, as written in Poland
+//	2015-W48-3          ISO week date, Wednesday of the 48th week of 2015
+//	2015-11             the last publication day of the month, up to today
+//	today, yesterday    days in Warsaw
+//	last-business-day   the last publication day before today
+//	-3d                 the number of days before today
+//
+// Like every date handled by the API, the result is midnight UTC.
+func ParseDate(s string) (time.Time, error) {
+	switch s {
+	case "today":
+		return Today(), nil
+	case "yesterday":
+		return Today().AddDate(0, 0, -1), nil
+	case "last-business-day":
+		return PreviousPublicationDay(Today()), nil
+	}
+
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("02.01.2006", s); err == nil {
+		return t, nil
+	}
+	if m := relative.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		return Today().AddDate(0, 0, -days), nil
+	}
+	if m := isoWeek.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		week, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		return weekDate(year, week, day)
+	}
+	if m := month.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		mon, _ := strconv.Atoi(m[2])
+		if mon < 1 || mon > 12 {
+			return time.Time{}, ErrBadDate
+		}
+		return lastPublicationDay(year, time.Month(mon)), nil
+	}
+	return time.Time{}, ErrBadDate
+}
+
+// weekDate returns the date of the day (1 for Monday) of the ISO week.
+func weekDate(year int, week int, day int) (time.Time, error) {
+	// January 4th is always in the first week
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7)
+	t := monday.AddDate(0, 0, (week-1)*7+day-1)
+
+	if y, w := t.ISOWeek(); y != year || w != week {
+		return time.Time{}, ErrBadDate
+	}
+	return t, nil
+}
+
+// lastPublicationDay returns the last publication day of the month. In the current month it's the last one
+// with both tables published by now, i.e. table A, the later one. For the months after the current one it's the first day, which is in the future.
+func lastPublicationDay(year int, month time.Month) time.Time {
+	today := Today()
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if first.After(today) {
+		return first
+	}
+
+	last := first.AddDate(0, 1, -1)
+	if last.After(today) {
+		last = today
+		if !Published(today, "A") {
+			last = last.AddDate(0, 0, -1)
+		}
+	}
+	if !IsPublicationDay(last) {
+		last = PreviousPublicationDay(last)
+	}
+	return last
+}
@@ -0,0 +1,105 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2015-11-25", "2015-11-25"},
+		{"25.11.2015", "2015-11-25"},
+		{"01.02.2016", "2016-02-01"},
+		{"2015-W48-3", "2015-11-25"},
+		{"2015-W01-1", "2014-12-29"},
+		{"2015-W53-5", "2016-01-01"},
+		{"2009-W01-1", "2008-12-29"},
+		// The last publication day of the month
+		{"2015-11", "2015-11-30"},
+		{"2016-01", "2016-01-29"},
+		{"2016-03", "2016-03-31"},
+		{"2025-12", "2025-12-31"},
+		{"2024-12", "2024-12-31"},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if err != nil {
+			t.Errorf("ParseDate(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(date(tt.want)) {
+			t.Errorf("ParseDate(%q) = %s, want %s", tt.in, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestParseDateRelative(t *testing.T) {
+	today := Today()
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"today", today},
+		{"yesterday", today.AddDate(0, 0, -1)},
+		{"last-business-day", PreviousPublicationDay(today)},
+		{"-0d", today},
+		{"-3d", today.AddDate(0, 0, -3)},
+		{"-365d", today.AddDate(0, 0, -365)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if err != nil {
+			t.Errorf("ParseDate(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %s, want %s", tt.in, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestParseDateCurrentMonth(t *testing.T) {
+	today := Today()
+	got, err := ParseDate(today.Format("2006-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.After(today) || !IsPublicationDay(got) {
+		t.Errorf("ParseDate(%q) = %s, want a publication day up to today", today.Format("2006-01"), got.Format("2006-01-02"))
+	}
+}
+
+func TestParseDateErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"tomorrow",
+		"2015-11-31",
+		"2015-1-5",
+		"32.11.2015",
+		"25-11-2015",
+		"2015-13",
+		"2015-00",
+		"2015-W54-1",
+		"2016-W53-1",
+		"2015-W48-0",
+		"2015-W48-8",
+		"-d",
+		"3d",
+		"-123456d",
+	} {
+		if got, err := ParseDate(in); err != ErrBadDate {
+			t.Errorf("ParseDate(%q) = %s, %v, want ErrBadDate", in, got.Format("2006-01-02"), err)
+		}
+	}
+}
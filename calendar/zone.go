@@ -0,0 +1,55 @@
+package calendar
+
+import (
+	"strings"
+	"time"
+
+	// The zone database is embedded, as the hosts like Heroku dynos don't always have it.
+	_ "time/tzdata"
+)
+
+// Warsaw is the time zone of NBP. The days and the publication times of the tables are given in it.
+var Warsaw = mustLoadLocation("Europe/Warsaw")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// publicationTimes are the hours and minutes in Warsaw by which NBP publishes the tables of the day, by their letters.
+// Table C is published around 8:15 and table A between 11:45 and 12:15.
+var publicationTimes = map[string][2]int{
+	"A": {12, 15},
+	"C": {8, 15},
+}
+
+// Now returns the current time in Warsaw.
+func Now() time.Time {
+	return time.Now().In(Warsaw)
+}
+
+// Today returns the current date in Warsaw. Like every date handled by the API, it's midnight UTC.
+func Today() time.Time {
+	y, m, d := Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// PublicationTime returns the time by which NBP publishes the table of the date, given by its letter ("A" or "C", in any case).
+// For other tables it's the time of table A, the last one published.
+func PublicationTime(t time.Time, table string) time.Time {
+	hm, ok := publicationTimes[strings.ToUpper(table)]
+	if !ok {
+		hm = publicationTimes["A"]
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, hm[0], hm[1], 0, 0, Warsaw)
+}
+
+// Published reports whether the table of the date should be published by now,
+// i.e. the date is a publication day and the publication time of the table has passed.
+func Published(t time.Time, table string) bool {
+	return IsPublicationDay(t) && Now().After(PublicationTime(t, table))
+}
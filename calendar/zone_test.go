@@ -0,0 +1,41 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublicationTime(t *testing.T) {
+	tests := []struct {
+		table string
+		want  string
+	}{
+		{"A", "2015-11-25T12:15:00+01:00"},
+		{"C", "2015-11-25T08:15:00+01:00"},
+		{"c", "2015-11-25T08:15:00+01:00"},
+		{"B", "2015-11-25T12:15:00+01:00"},
+		{"", "2015-11-25T12:15:00+01:00"},
+	}
+	for _, tt := range tests {
+		if got := PublicationTime(date("2015-11-25"), tt.table).Format(time.RFC3339); got != tt.want {
+			t.Errorf("PublicationTime(%q) = %s, want %s", tt.table, got, tt.want)
+		}
+	}
+
+	// Summer time
+	if got := PublicationTime(date("2015-07-01"), "C").UTC().Format(time.RFC3339); got != "2015-07-01T06:15:00Z" {
+		t.Errorf("PublicationTime in summer = %s, want 2015-07-01T06:15:00Z", got)
+	}
+}
+
+func TestPublished(t *testing.T) {
+	if !Published(date("2015-11-25"), "A") {
+		t.Error("table of 2015-11-25 isn't published")
+	}
+	if Published(date("2015-12-25"), "A") {
+		t.Error("table of the holiday is published")
+	}
+	if Published(Today().AddDate(0, 0, 1), "C") {
+		t.Error("table of tomorrow is published")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// conversion is the result of convert command.
+type conversion struct {
+	Amount      string `json:"amount"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Result      string `json:"result"`
+	Date        string `json:"date"`
+	TableNumber string `json:"tableNumber"`
+}
+
+// convertCmd converts the amount between two currencies using the average rates from table A.
+// PLN can be used on both sides.
+func convertCmd(b svc.Backend, args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	dateFlag := fs.String("date", "", "date of the rates, YYYY-MM-DD (default today)")
+	format := fs.String("format", "text", "output format: text or json")
+	pos := parseArgs(fs, args)
+
+	if len(pos) != 3 {
+		return usageError("convert needs AMOUNT, FROM and TO")
+	}
+	amount, err := svc.ParseDecimal(pos[0])
+	if err != nil {
+		return usageError("wrong amount " + pos[0])
+	}
+	from, to := strings.ToUpper(pos[1]), strings.ToUpper(pos[2])
+	date, ok := parseDate(*dateFlag)
+	if !ok {
+		return usageError("wrong date " + *dateFlag + ", use YYYY-MM-DD since 2002-01-02")
+	}
+	if *format != "text" && *format != "json" {
+		return usageError("wrong format " + *format)
+	}
+
+	q, err := svc.LastTable(b, date, "avg", "*")
+	if err != nil {
+		return fail(err)
+	}
+
+	res, err := svc.Convert(q, amount, from, to)
+	if err == svc.ErrNoCurrency {
+		return fail(notFound{errors.New("currency " + from + " or " + to + " not found in table " + q.TableNumber)})
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	c := conversion{
+		Amount:      pos[0],
+		From:        from,
+		To:          to,
+		Result:      svc.FormatDecimal(res, 2),
+		Date:        q.FromData,
+		TableNumber: q.TableNumber,
+	}
+	if *format == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(c)
+	} else {
+		_, err = fmt.Printf("%s %s = %s %s (table %s of %s)\n", c.Amount, c.From, c.Result, c.To, c.TableNumber, c.Date)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// currenciesCmd prints the codes and names of the currencies in the last table.
+func currenciesCmd(b svc.Backend, args []string) int {
+	fs := flag.NewFlagSet("currencies", flag.ExitOnError)
+	table := fs.String("table", "a", "table: a for average rates, c for buy and sell rates")
+	dateFlag := fs.String("date", "", "date of the table, YYYY-MM-DD (default today)")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	if pos := parseArgs(fs, args); len(pos) > 0 {
+		return usageError("currencies doesn't take arguments")
+	}
+
+	date, ok := parseDate(*dateFlag)
+	if !ok {
+		return usageError("wrong date " + *dateFlag + ", use YYYY-MM-DD since 2002-01-02")
+	}
+	sType, ok := tableType(*table)
+	if !ok {
+		return usageError("wrong table " + *table + ", use a or c")
+	}
+
+	q, err := svc.LastTable(b, date, sType, "*")
+	if err != nil {
+		return fail(err)
+	}
+
+	switch *format {
+	case "json":
+		type cur struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		}
+		res := []cur{}
+		for _, c := range q.Currencies {
+			res = append(res, cur{c.Code, c.Name})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	case "csv":
+		cw := csv.NewWriter(os.Stdout)
+		cw.Write([]string{"code", "name"})
+		for _, c := range q.Currencies {
+			cw.Write([]string{c.Code, c.Name})
+		}
+		cw.Flush()
+		err = cw.Error()
+	case "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, c := range q.Currencies {
+			fmt.Fprintf(tw, "%s\t%s\n", c.Code, c.Name)
+		}
+		err = tw.Flush()
+	default:
+		return usageError("wrong format " + *format)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,177 @@
+// Command nbp queries NBP currency rates from the terminal.
+//
+// Usage:
+//
+//	nbp rate DATE [CODES] [--table a|c] [--format text|csv|json]
+//	nbp convert AMOUNT FROM TO [--date DATE] [--format text|json]
+//	nbp range FROM TO [CODES] [--table a|c] [--format text|csv|json]
+//	nbp currencies [--date DATE] [--table a|c] [--format text|csv|json]
+//	nbp sync [--from DATE] [--to DATE] [--out DIR] [--tables abc] [--format json|csv] [--rate 250ms]
+//
+// Dates are given in the formats the server accepts, e.g. YYYY-MM-DD, yesterday or -3d for 3 days ago,
+// codes as "*" or comma separated list like USD,EUR. The arguments after "--" aren't flags.
+// The backend is selected with NBP_BACKEND environment variable, as for the server. sync always reads the XML files.
+//
+// Exit codes: 0 on success, 1 when NBP couldn't be queried, 2 on wrong usage
+// and 3 when the rates were not found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+)
+
+const (
+	exitOK       = 0
+	exitUpstream = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// command runs the subcommand with given arguments and returns the exit code.
+type command func(b svc.Backend, args []string) int
+
+var commands = map[string]command{
+	"rate":       rateCmd,
+	"convert":    convertCmd,
+	"range":      rangeCmd,
+	"currencies": currenciesCmd,
+	"sync":       syncCmd,
+}
+
+const usage = `Usage:
+  nbp rate DATE [CODES] [--table a|c] [--format text|csv|json]
+  nbp convert AMOUNT FROM TO [--date DATE] [--format text|json]
+  nbp range FROM TO [CODES] [--table a|c] [--format text|csv|json]
+  nbp currencies [--date DATE] [--table a|c] [--format text|csv|json]
+  nbp sync [--from DATE] [--to DATE] [--out DIR] [--tables abc] [--format json|csv] [--rate 250ms]
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "nbp: unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(exitUsage)
+	}
+
+	b, err := svc.NewBackend(os.Getenv("NBP_BACKEND"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "nbp:", err)
+		os.Exit(exitUsage)
+	}
+
+	os.Exit(cmd(b, os.Args[2:]))
+}
+
+// relativeDate is the date given as days ago, e.g. -3d, which would be taken for a flag.
+var relativeDate = regexp.MustCompile(`^-\d+d$`)
+
+// parseArgs parses the flags which can be given before, after or between the positional arguments,
+// e.g. "nbp rate 2015-11-25 USD --table c". It returns the positional arguments, including the relative dates
+// like -3d and all the arguments after "--".
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var pos []string
+	for len(args) > 0 {
+		if args[0] == "--" {
+			return append(pos, args[1:]...)
+		}
+		if relativeDate.MatchString(args[0]) {
+			pos = append(pos, args[0])
+			args = args[1:]
+			continue
+		}
+
+		// The flag set exits with exitUsage on error, so it's given the flags before the next relative date only
+		n := flagsBeforeDate(fs, args)
+		fs.Parse(args[:n])
+		rest := fs.Args()
+		if len(rest) < n && args[n-len(rest)-1] == "--" {
+			return append(append(pos, rest...), args[n:]...)
+		}
+		if len(rest) == 0 {
+			args = args[n:]
+			continue
+		}
+		pos = append(pos, rest[0])
+		args = append(append([]string{}, rest[1:]...), args[n:]...)
+	}
+	return pos
+}
+
+// flagsBeforeDate returns the number of the arguments before the first relative date which isn't a value of a flag,
+// e.g. 2 for "--table c -3d USD", and 4 for "--date -3d --table c".
+func flagsBeforeDate(fs *flag.FlagSet, args []string) int {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		if relativeDate.MatchString(a) {
+			return i
+		}
+		// The value of the flag given as "--date -3d" is the next argument, unless it's a bool flag
+		name := strings.TrimLeft(a, "-")
+		if !strings.HasPrefix(a, "-") || strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++
+			}
+		}
+	}
+	return len(args)
+}
+
+// usageError prints the message and returns exitUsage.
+func usageError(msg string) int {
+	fmt.Fprintf(os.Stderr, "nbp: %s\n%s", msg, usage)
+	return exitUsage
+}
+
+// notFound is the error returned when the rates were not found in the tables published by NBP.
+type notFound struct{ error }
+
+// fail prints the error and returns the exit code reflecting it.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "nbp:", err)
+	if _, ok := err.(notFound); ok || err == svc.ErrNotPublished {
+		return exitNotFound
+	}
+	return exitUpstream
+}
+
+// tableType returns the type of the table given with --table flag.
+func tableType(table string) (string, bool) {
+	switch table {
+	case "a", "A", "avg":
+		return "avg", true
+	case "c", "C", "both":
+		return "both", true
+	}
+	return "", false
+}
+
+// parseDate parses the date given in the arguments. Empty date means today.
+func parseDate(s string) (time.Time, bool) {
+	if s == "" {
+		return calendar.Today(), true
+	}
+	date, err := calendar.ParseDate(s)
+	if err != nil || date.Before(svc.MinDate) {
+		return date, false
+	}
+	return date, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args  string
+		pos   []string
+		table string
+		date  string
+		json  bool
+	}{
+		{"2015-11-25 USD --table c", []string{"2015-11-25", "USD"}, "c", "", false},
+		{"--table c 2015-11-25 USD", []string{"2015-11-25", "USD"}, "c", "", false},
+		{"2015-11-25 --table=c USD --json", []string{"2015-11-25", "USD"}, "c", "", true},
+		// The relative dates aren't flags
+		{"-3d USD", []string{"-3d", "USD"}, "a", "", false},
+		{"-3d", []string{"-3d"}, "a", "", false},
+		{"USD -3d --table c", []string{"USD", "-3d"}, "c", "", false},
+		{"--table c -3d USD", []string{"-3d", "USD"}, "c", "", false},
+		{"--json -3d", []string{"-3d"}, "a", "", true},
+		// unless they're the values of the flags
+		{"--date -3d USD", []string{"USD"}, "a", "-3d", false},
+		{"USD --date -10d --table c", []string{"USD"}, "c", "-10d", false},
+		// The arguments after -- are positional
+		{"--table c -- -3d --json", []string{"-3d", "--json"}, "c", "", false},
+		{"USD -- --table", []string{"USD", "--table"}, "a", "", false},
+		{"", nil, "a", "", false},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		table := fs.String("table", "a", "")
+		date := fs.String("date", "", "")
+		json := fs.Bool("json", false, "")
+
+		pos := parseArgs(fs, strings.Fields(tt.args))
+		if !reflect.DeepEqual(pos, tt.pos) || *table != tt.table || *date != tt.date || *json != tt.json {
+			t.Errorf("parseArgs(%q) = %q, table %q, date %q, json %v, want %q, %q, %q, %v",
+				tt.args, pos, *table, *date, *json, tt.pos, tt.table, tt.date, tt.json)
+		}
+	}
+}
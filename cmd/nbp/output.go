@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+var header = []string{"date", "table", "code", "name", "ratio", "average", "buy", "sell"}
+
+// writeTables writes the rates from the tables in the given format: text, csv or json.
+func writeTables(w io.Writer, format string, tables []svc.Query) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tables)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, t := range tables {
+			for _, c := range t.Currencies {
+				cw.Write([]string{t.FromData, t.TableNumber, c.Code, c.Name, c.Ratio, c.Average, c.Buy, c.Sell})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "text", "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DATE\tTABLE\tCODE\tRATIO\tAVERAGE\tBUY\tSELL\tNAME")
+		for _, t := range tables {
+			for _, c := range t.Currencies {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.FromData, t.TableNumber, c.Code, c.Ratio, c.Average, c.Buy, c.Sell, c.Name)
+			}
+		}
+		return tw.Flush()
+	}
+	return errors.New("unknown format " + format)
+}
+
+// validFormat reports whether the tables can be written in the format.
+func validFormat(format string) bool {
+	return format == "text" || format == "csv" || format == "json"
+}
+
+// countRates returns the number of currencies in all tables.
+func countRates(tables []svc.Query) int {
+	n := 0
+	for _, t := range tables {
+		n += len(t.Currencies)
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// rangeCmd prints the rates from all tables published between two dates.
+func rangeCmd(b svc.Backend, args []string) int {
+	fs := flag.NewFlagSet("range", flag.ExitOnError)
+	table := fs.String("table", "a", "table: a for average rates, c for buy and sell rates")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	pos := parseArgs(fs, args)
+
+	if len(pos) < 2 || len(pos) > 3 {
+		return usageError("range needs FROM, TO and optional CODES")
+	}
+	from, ok := parseDate(pos[0])
+	if !ok {
+		return usageError("wrong date " + pos[0] + ", use YYYY-MM-DD since 2002-01-02")
+	}
+	to, ok := parseDate(pos[1])
+	if !ok || to.Before(from) {
+		return usageError("wrong date " + pos[1] + ", use YYYY-MM-DD not before " + pos[0])
+	}
+	code := "*"
+	if len(pos) == 3 {
+		code = pos[2]
+	}
+	sType, ok := tableType(*table)
+	if !ok {
+		return usageError("wrong table " + *table + ", use a or c")
+	}
+	if !validFormat(*format) {
+		return usageError("wrong format " + *format)
+	}
+
+	tables, err := b.Tables(from, to, sType, code)
+	if err != nil {
+		return fail(err)
+	}
+	if countRates(tables) == 0 {
+		return fail(notFound{errors.New("no rates for " + code + " between " + pos[0] + " and " + pos[1])})
+	}
+
+	if err := writeTables(os.Stdout, *format, tables); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
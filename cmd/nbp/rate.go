@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// rateCmd prints the rates from the table published on the date or the last one before it.
+func rateCmd(b svc.Backend, args []string) int {
+	fs := flag.NewFlagSet("rate", flag.ExitOnError)
+	table := fs.String("table", "a", "table: a for average rates, c for buy and sell rates")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	pos := parseArgs(fs, args)
+
+	if len(pos) < 1 || len(pos) > 2 {
+		return usageError("rate needs DATE and optional CODES")
+	}
+	date, ok := parseDate(pos[0])
+	if !ok {
+		return usageError("wrong date " + pos[0] + ", use YYYY-MM-DD since 2002-01-02")
+	}
+	code := "*"
+	if len(pos) == 2 {
+		code = pos[1]
+	}
+	sType, ok := tableType(*table)
+	if !ok {
+		return usageError("wrong table " + *table + ", use a or c")
+	}
+	if !validFormat(*format) {
+		return usageError("wrong format " + *format)
+	}
+
+	q, err := svc.LastTable(b, date, sType, code)
+	if err != nil {
+		return fail(err)
+	}
+	if len(q.Currencies) == 0 {
+		return fail(notFound{errors.New("no rates for " + code + " in table " + q.TableNumber)})
+	}
+
+	if err := writeTables(os.Stdout, *format, []svc.Query{q}); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// record is a table stored by sync command, one per line of the JSON file.
+type record struct {
+	File string `json:"file"`
+	Type string `json:"type"`
+	svc.Query
+}
+
+var csvHeader = []string{"file", "type", "date", "table", "code", "name", "ratio", "average", "buy", "sell"}
+
+// syncCmd downloads all tables listed in the dir indexes between two dates and stores them in a file per year,
+// e.g. data/2015.json. The tables already stored are skipped, so it's safe to re-run it to fetch the new ones.
+// It always reads the XML files, whatever the backend is, as only they list the tables B.
+func syncCmd(_ svc.Backend, args []string) int {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	fromFlag := fs.String("from", svc.MinDate.Format("2006-01-02"), "first date, YYYY-MM-DD")
+	toFlag := fs.String("to", "", "last date, YYYY-MM-DD (default today)")
+	out := fs.String("out", "data", "directory to store the tables in")
+	tables := fs.String("tables", "abc", "tables to download: a, b and/or c")
+	format := fs.String("format", "json", "file format: json (JSON lines) or csv")
+	rate := fs.Duration("rate", 250*time.Millisecond, "minimal interval between the downloads")
+	if pos := parseArgs(fs, args); len(pos) > 0 {
+		return usageError("sync doesn't take arguments")
+	}
+
+	from, ok := parseDate(*fromFlag)
+	if !ok {
+		return usageError("wrong date " + *fromFlag + ", use YYYY-MM-DD since 2002-01-02")
+	}
+	to, ok := parseDate(*toFlag)
+	if !ok || to.Before(from) {
+		return usageError("wrong date " + *toFlag + ", use YYYY-MM-DD not before " + *fromFlag)
+	}
+	if *format != "json" && *format != "csv" {
+		return usageError("wrong format " + *format + ", use json or csv")
+	}
+	if *rate <= 0 {
+		return usageError("wrong rate " + rate.String())
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return fail(err)
+	}
+
+	tick := time.NewTicker(*rate)
+	defer tick.Stop()
+
+	for year := from.Year(); year <= to.Year(); year++ {
+		path := filepath.Join(*out, strconv.Itoa(year)+"."+*format)
+		stored, err := storedFiles(path, *format)
+		if err != nil {
+			return fail(err)
+		}
+
+		entries, err := svc.GetIndex(year)
+		if err != nil {
+			return fail(err)
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fail(err)
+		}
+		idx, err := os.OpenFile(indexPath(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			f.Close()
+			return fail(err)
+		}
+
+		n := 0
+		for _, e := range entries {
+			if stored[e.File] || !strings.Contains(*tables, e.Type) || e.Date.Before(from) || e.Date.After(to) {
+				continue
+			}
+
+			<-tick.C
+			q, err := svc.GetData(e.File, "*")
+			if err != nil {
+				f.Close()
+				idx.Close()
+				return fail(fmt.Errorf("%s: %v", e.File, err))
+			}
+			if err := appendRecord(f, idx, *format, record{File: e.File, Type: e.Type, Query: q}); err != nil {
+				f.Close()
+				idx.Close()
+				return fail(err)
+			}
+			n++
+		}
+
+		if err := idx.Close(); err != nil {
+			f.Close()
+			return fail(err)
+		}
+		if err := f.Close(); err != nil {
+			return fail(err)
+		}
+		fmt.Fprintf(os.Stderr, "%d: %d new tables in %s\n", year, n, path)
+	}
+	return exitOK
+}
+
+// appendRecord writes the record at the end of the file in a single write and syncs the file.
+// Then it adds the table with the size of the file to the index, which marks the table as complete.
+// A table cut off by an interrupted sync isn't in the index, so it's removed and downloaded again.
+func appendRecord(f *os.File, idx *os.File, format string, r record) error {
+	var buf bytes.Buffer
+	if format == "json" {
+		if err := json.NewEncoder(&buf).Encode(r); err != nil {
+			return err
+		}
+	} else {
+		cw := csv.NewWriter(&buf)
+		if st, err := f.Stat(); err == nil && st.Size() == 0 {
+			cw.Write(csvHeader)
+		}
+		for _, c := range r.Currencies {
+			cw.Write([]string{r.File, r.Type, r.FromData, r.TableNumber, c.Code, c.Name, c.Ratio, c.Average, c.Buy, c.Sell})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(idx, "%s %d\n", r.File, st.Size()); err != nil {
+		return err
+	}
+	return idx.Sync()
+}
+
+// indexPath returns the path of the index of the year file, e.g. data/2015.csv.index.
+// Every line of the index is the name of a table stored completely and the size of the year file after it.
+func indexPath(path string) string {
+	return path + ".index"
+}
+
+// storedFiles returns the names of the tables stored completely in the year file, as listed in its index.
+// Whatever was written after the last complete table by an interrupted sync is removed.
+// The index is rebuilt from the year file when it's missing.
+func storedFiles(path string, format string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		// The index left without its year file would skip the tables
+		if err := os.Remove(indexPath(path)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := ioutil.ReadFile(indexPath(path))
+	if os.IsNotExist(err) {
+		index = rebuildIndex(data, format)
+		if err := ioutil.WriteFile(indexPath(path), index, 0644); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	stored := map[string]bool{}
+	var size int64
+	complete := 0
+	for complete < len(index) {
+		i := bytes.IndexByte(index[complete:], '\n')
+		if i < 0 {
+			break
+		}
+		fields := strings.Fields(string(index[complete : complete+i]))
+		if len(fields) != 2 {
+			break
+		}
+		end, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || end < size || end > int64(len(data)) {
+			break
+		}
+		stored[fields[0]] = true
+		size = end
+		complete += i + 1
+	}
+
+	if complete < len(index) {
+		if err := os.Truncate(indexPath(path), int64(complete)); err != nil {
+			return nil, err
+		}
+	}
+	if size < int64(len(data)) {
+		if err := os.Truncate(path, size); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// rebuildIndex lists the tables in the year file written without the index.
+// In CSV the rows of the last table can't be told complete, so it's left out to be downloaded again.
+func rebuildIndex(data []byte, format string) []byte {
+	var index bytes.Buffer
+	last, start := "", 0
+	for end := 0; end < len(data); {
+		i := bytes.IndexByte(data[end:], '\n')
+		if i < 0 {
+			break
+		}
+		line := data[end : end+i]
+		next := end + i + 1
+
+		if format == "json" {
+			var r record
+			if err := json.Unmarshal(line, &r); err != nil {
+				break
+			}
+			fmt.Fprintf(&index, "%s %d\n", r.File, next)
+		} else if j := bytes.IndexByte(line, ','); j > 0 && string(line[:j]) != csvHeader[0] {
+			if file := string(line[:j]); file != last {
+				if last != "" {
+					fmt.Fprintf(&index, "%s %d\n", last, start)
+				}
+				last, start = file, end
+			}
+		}
+		end = next
+	}
+	return index.Bytes()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+var syncTables = []record{
+	{File: "a228z151124", Type: "a", Query: svc.Query{FromData: "2015-11-24", TableNumber: "228/A/NBP/2015"}},
+	{File: "c228z151124", Type: "c", Query: svc.Query{FromData: "2015-11-24", TableNumber: "228/C/NBP/2015"}},
+}
+
+func init() {
+	// The currencies can only be built by svc, so they're decoded
+	for i := range syncTables {
+		err := json.Unmarshal([]byte(`{"currencies":[{"code":"USD","name":"dolar amerykański","ratio":"1","average":"3,8280"},{"code":"EUR","name":"euro","ratio":"1","average":"4,2608"}]}`), &syncTables[i].Query)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// writeYear stores the tables the way sync does and returns the path of the year file.
+func writeYear(t *testing.T, format string) string {
+	path := filepath.Join(t.TempDir(), "2015."+format)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idx, err := os.OpenFile(indexPath(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	for _, r := range syncTables {
+		if err := appendRecord(f, idx, format, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestStoredFiles(t *testing.T) {
+	for _, format := range []string{"json", "csv"} {
+		path := writeYear(t, format)
+		data, _ := ioutil.ReadFile(path)
+		index, _ := ioutil.ReadFile(indexPath(path))
+		complete := len(data)
+		lastLine := bytes.LastIndexByte(data[:len(data)-1], '\n') + 1
+
+		tests := []struct {
+			name  string
+			cut   int
+			index bool
+			want  map[string]bool
+		}{
+			{"complete", len(data), true, map[string]bool{"a228z151124": true, "c228z151124": true}},
+			// The table written without its index line
+			{"not indexed", len(data), false, map[string]bool{"a228z151124": true}},
+			// The table cut off at the end of a line, which looks complete in CSV
+			{"cut after line", lastLine, false, map[string]bool{"a228z151124": true}},
+			{"cut in line", len(data) - 5, false, map[string]bool{"a228z151124": true}},
+		}
+		for _, tt := range tests {
+			ioutil.WriteFile(path, data[:tt.cut], 0644)
+			if tt.index {
+				ioutil.WriteFile(indexPath(path), index, 0644)
+			} else {
+				// The second index line is lost, with a part of it written
+				i := len(index) - 4
+				ioutil.WriteFile(indexPath(path), index[:i], 0644)
+			}
+
+			got, err := storedFiles(path, format)
+			if err != nil {
+				t.Errorf("%s %s: %v", format, tt.name, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s %s: storedFiles = %v, want %v", format, tt.name, got, tt.want)
+			}
+
+			// Only the complete tables are left in the year file
+			st, _ := os.Stat(path)
+			if tt.index && st.Size() != int64(complete) {
+				t.Errorf("%s %s: year file size = %d, want %d", format, tt.name, st.Size(), complete)
+			}
+			if !tt.index && st.Size() >= int64(complete) {
+				t.Errorf("%s %s: the incomplete table wasn't removed", format, tt.name)
+			}
+		}
+	}
+}
+
+func TestStoredFilesRebuildIndex(t *testing.T) {
+	tests := []struct {
+		format string
+		want   map[string]bool
+	}{
+		{"json", map[string]bool{"a228z151124": true, "c228z151124": true}},
+		// The last table in CSV can't be told complete
+		{"csv", map[string]bool{"a228z151124": true}},
+	}
+	for _, tt := range tests {
+		path := writeYear(t, tt.format)
+		os.Remove(indexPath(path))
+
+		got, err := storedFiles(path, tt.format)
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: storedFiles = %v, want %v", tt.format, got, tt.want)
+		}
+		// The rebuilt index is used from now on
+		if again, _ := storedFiles(path, tt.format); !reflect.DeepEqual(again, tt.want) {
+			t.Errorf("%s: storedFiles with the rebuilt index = %v, want %v", tt.format, again, tt.want)
+		}
+	}
+}
+
+func TestStoredFilesWithoutYearFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "2015.csv")
+	ioutil.WriteFile(indexPath(path), []byte("a228z151124 100\n"), 0644)
+
+	got, err := storedFiles(path, "csv")
+	if err != nil || len(got) != 0 {
+		t.Errorf("storedFiles = %v, %v, want no tables", got, err)
+	}
+	if _, err := os.Stat(indexPath(path)); !os.IsNotExist(err) {
+		t.Error("the index without the year file wasn't removed")
+	}
+}
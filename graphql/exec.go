@@ -0,0 +1,378 @@
+// Package graphql executes GraphQL queries against a schema of objects with resolver functions.
+//
+// It implements the part of GraphQL needed to serve read-only APIs: queries with aliases, arguments,
+// variables, fragments and @skip/@include directives. Mutations, subscriptions and introspection are not supported.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Schema is the GraphQL schema with Query as the root type.
+type Schema struct {
+	Query *Object
+	// MaxFields is how many fields of Query a request can select, unlimited when 0.
+	MaxFields int
+}
+
+// Object is an object type.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type.
+type Field struct {
+	// Type is the object type of the value, or of every element when the value is a slice.
+	// It's nil for scalars and lists of scalars.
+	Type *Object
+	// Args are the arguments the field accepts.
+	Args map[string]Arg
+	// Resolve returns the value of the field. When it's nil, the value is taken from the source,
+	// which has to be map[string]interface{}.
+	Resolve func(p Params) (interface{}, error)
+}
+
+// Arg is an argument of a field. When it's not given, the Default is used, unless it's Required.
+type Arg struct {
+	Default  interface{}
+	Required bool
+}
+
+// Params are given to the resolvers.
+type Params struct {
+	// Source is the value of the parent field.
+	Source interface{}
+	// Args are the arguments of the field: strings, ints, float64s, bools, nils,
+	// []interface{} for lists and map[string]interface{} for input objects.
+	Args map[string]interface{}
+}
+
+// Request is the GraphQL request, as sent in the body of POST request.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	// Admit, when set, is given the number of the fields of Query selected, before any of them is resolved.
+	// Its error fails the whole query, e.g. when the client can't afford that many.
+	Admit func(fields int) error `json:"-"`
+}
+
+// Response is the result of the query. Data is nil when the query couldn't be executed at all.
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is an error of the query or of a single field, given by its path.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute executes the query of the request.
+func (s *Schema) Execute(r Request) Response {
+	doc, err := parse(r.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := doc.operation(r.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.typ != "query" {
+		return Response{Errors: []Error{{Message: "Only queries are supported"}}}
+	}
+
+	vars, err := coerceVariables(op.vars, r.Variables)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	e := &executor{doc: doc, vars: vars}
+	fields := e.collect(s.Query, op.sel, map[string]bool{})
+	if s.MaxFields > 0 && len(fields) > s.MaxFields {
+		return Response{Errors: []Error{{Message: fmt.Sprintf("Query selects %d fields, at most %d are allowed", len(fields), s.MaxFields)}}}
+	}
+	if r.Admit != nil {
+		if err := r.Admit(len(fields)); err != nil {
+			return Response{Errors: []Error{{Message: err.Error()}}}
+		}
+	}
+	data := e.fields(s.Query, nil, fields, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+func (doc *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("Operation name is required when the document has more operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown operation %q", name)
+}
+
+func coerceVariables(defs []varDef, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, d := range defs {
+		v, ok := given[d.name]
+		if !ok && d.hasDefault {
+			v, ok = resolveValue(d.def, nil), true
+		}
+		if d.typ.nonNull && (!ok || v == nil) {
+			return nil, fmt.Errorf("Variable $%s of required type was not provided", d.name)
+		}
+		if ok {
+			vars[d.name] = v
+		}
+	}
+	return vars, nil
+}
+
+// resolveValue replaces the variables in the value and converts it to the values given to the resolvers.
+func resolveValue(v interface{}, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return vars[string(v)]
+	case enum:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = resolveValue(e, vars)
+		}
+		return list
+	case []argument:
+		obj := map[string]interface{}{}
+		for _, f := range v {
+			obj[f.name] = resolveValue(f.value, vars)
+		}
+		return obj
+	}
+	return v
+}
+
+type executor struct {
+	doc    *document
+	vars   map[string]interface{}
+	errors []Error
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: append([]interface{}{}, path...)})
+}
+
+// object resolves the selected fields of the object.
+func (e *executor) object(obj *Object, source interface{}, sel []selection, path []interface{}) orderedMap {
+	return e.fields(obj, source, e.collect(obj, sel, map[string]bool{}), path)
+}
+
+// fields resolves the fields collected from the selection of the object.
+func (e *executor) fields(obj *Object, source interface{}, fields []*field, path []interface{}) orderedMap {
+	res := orderedMap{}
+	for _, f := range fields {
+		fpath := append(path, f.alias)
+
+		if f.name == "__typename" {
+			res = res.set(f.alias, obj.Name)
+			continue
+		}
+
+		def, ok := obj.Fields[f.name]
+		if !ok {
+			e.fail(fpath, fmt.Errorf("Cannot query field %q on type %q", f.name, obj.Name))
+			res = res.set(f.alias, nil)
+			continue
+		}
+
+		res = res.set(f.alias, e.field(def, source, f, fpath))
+	}
+	return res
+}
+
+func (e *executor) field(def *Field, source interface{}, f *field, path []interface{}) interface{} {
+	args, err := e.arguments(def, f)
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+
+	var v interface{}
+	if def.Resolve != nil {
+		v, err = def.Resolve(Params{Source: source, Args: args})
+	} else if m, ok := source.(map[string]interface{}); ok {
+		v = m[f.name]
+	}
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+
+	return e.complete(def.Type, v, f, path)
+}
+
+// complete resolves the selection of the value, which can be a slice of objects.
+func (e *executor) complete(typ *Object, v interface{}, f *field, path []interface{}) interface{} {
+	if typ == nil {
+		if len(f.sel) > 0 {
+			e.fail(path, fmt.Errorf("Field %q must not have a selection since it's a scalar", f.name))
+			return nil
+		}
+		return v
+	}
+	if len(f.sel) == 0 {
+		e.fail(path, fmt.Errorf("Field %q of type %q must have a selection of subfields", f.name, typ.Name))
+		return nil
+	}
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+	if rv.Kind() != reflect.Slice {
+		return e.object(typ, v, f.sel, path)
+	}
+
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = e.object(typ, rv.Index(i).Interface(), f.sel, append(path, i))
+	}
+	return list
+}
+
+func (e *executor) arguments(def *Field, f *field) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, a := range f.args {
+		if _, ok := def.Args[a.name]; !ok {
+			return nil, fmt.Errorf("Unknown argument %q on field %q", a.name, f.name)
+		}
+		args[a.name] = resolveValue(a.value, e.vars)
+	}
+	for name, a := range def.Args {
+		if v, ok := args[name]; ok && v != nil {
+			continue
+		}
+		if a.Required {
+			return nil, fmt.Errorf("Argument %q of field %q is required", name, f.name)
+		}
+		args[name] = a.Default
+	}
+	return args, nil
+}
+
+// collect returns the fields selected on the object, including the ones from the fragments.
+// The fields with the same alias are merged.
+func (e *executor) collect(obj *Object, sel []selection, visited map[string]bool) []*field {
+	var fields []*field
+	byAlias := map[string]*field{}
+
+	add := func(f *field) {
+		if prev, ok := byAlias[f.alias]; ok {
+			merged := *prev
+			merged.sel = append(append([]selection{}, prev.sel...), f.sel...)
+			*prev = merged
+			return
+		}
+		c := *f
+		byAlias[f.alias] = &c
+		fields = append(fields, &c)
+	}
+
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *field:
+			if e.included(s.directives) {
+				add(s)
+			}
+		case *fragmentSpread:
+			frag, ok := e.doc.fragments[s.name]
+			if !ok || visited[s.name] || !e.included(s.directives) || frag.typeCond != obj.Name {
+				continue
+			}
+			visited[s.name] = true
+			for _, f := range e.collect(obj, frag.sel, visited) {
+				add(f)
+			}
+		case *inlineFragment:
+			if !e.included(s.directives) || (s.typeCond != "" && s.typeCond != obj.Name) {
+				continue
+			}
+			for _, f := range e.collect(obj, s.sel, visited) {
+				add(f)
+			}
+		}
+	}
+	return fields
+}
+
+// included evaluates @skip and @include directives.
+func (e *executor) included(ds []directive) bool {
+	for _, d := range ds {
+		for _, a := range d.args {
+			if a.name != "if" {
+				continue
+			}
+			cond, _ := resolveValue(a.value, e.vars).(bool)
+			if d.name == "skip" && cond || d.name == "include" && !cond {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// orderedMap keeps the fields in the order they were selected, as required by the spec.
+type orderedMap []keyValue
+
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+func (m orderedMap) set(key string, value interface{}) orderedMap {
+	for i := range m {
+		if m[i].key == key {
+			m[i].value = value
+			return m
+		}
+	}
+	return append(m, keyValue{key, value})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, kv := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(kv.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(kv.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,251 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var testCurrency = &Object{
+	Name:   "Currency",
+	Fields: map[string]*Field{"code": {}, "average": {}},
+}
+
+var testTable = &Object{
+	Name: "Table",
+	Fields: map[string]*Field{
+		"date":   {},
+		"number": {},
+		"currencies": {
+			Type: testCurrency,
+			Args: map[string]Arg{"code": {}},
+			Resolve: func(p Params) (interface{}, error) {
+				all := p.Source.(map[string]interface{})["currencies"].([]map[string]interface{})
+				if p.Args["code"] == nil {
+					return all, nil
+				}
+				for _, c := range all {
+					if c["code"] == p.Args["code"] {
+						return []map[string]interface{}{c}, nil
+					}
+				}
+				return nil, errors.New("Currency not found")
+			},
+		},
+	},
+}
+
+var testSchema = &Schema{
+	Query: &Object{
+		Name: "Query",
+		Fields: map[string]*Field{
+			"table": {
+				Type: testTable,
+				Args: map[string]Arg{"date": {Required: true}, "type": {Default: "avg"}},
+				Resolve: func(p Params) (interface{}, error) {
+					if p.Args["date"] != "2015-11-25" {
+						return nil, errors.New("Table not found")
+					}
+					return map[string]interface{}{
+						"date":   "2015-11-25",
+						"number": "229/A/NBP/2015",
+						"currencies": []map[string]interface{}{
+							{"code": "USD", "average": "3,8290"},
+							{"code": "EUR", "average": "4,2608"},
+						},
+					}, nil
+				},
+			},
+			"echo": {
+				Args: map[string]Arg{"value": {}},
+				Resolve: func(p Params) (interface{}, error) {
+					return p.Args["value"], nil
+				},
+			},
+		},
+	},
+	MaxFields: 3,
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			"fields",
+			Request{Query: `{ table(date: "2015-11-25") { date number } }`},
+			`{"data":{"table":{"date":"2015-11-25","number":"229/A/NBP/2015"}}}`,
+		},
+		{
+			"aliases in selection order",
+			Request{Query: `{ t: table(date: "2015-11-25") { n: number date __typename } __typename }`},
+			`{"data":{"t":{"n":"229/A/NBP/2015","date":"2015-11-25","__typename":"Table"},"__typename":"Query"}}`,
+		},
+		{
+			"lists and arguments",
+			Request{Query: `{ table(date: "2015-11-25") { all: currencies { code } usd: currencies(code: "USD") { average } } }`},
+			`{"data":{"table":{"all":[{"code":"USD"},{"code":"EUR"}],"usd":[{"average":"3,8290"}]}}}`,
+		},
+		{
+			"variables",
+			Request{
+				Query:     `query Q($date: String!, $code: String) { table(date: $date) { currencies(code: $code) { code } } }`,
+				Variables: map[string]interface{}{"date": "2015-11-25", "code": "EUR"},
+			},
+			`{"data":{"table":{"currencies":[{"code":"EUR"}]}}}`,
+		},
+		{
+			"variable defaults",
+			Request{Query: `query ($date: String = "2015-11-25") { table(date: $date) { date } }`},
+			`{"data":{"table":{"date":"2015-11-25"}}}`,
+		},
+		{
+			"values",
+			Request{Query: `{ a: echo(value: [1, 2.5, true, null, ENUM, {x: "y"}]) b: echo }`},
+			`{"data":{"a":[1,2.5,true,null,"ENUM",{"x":"y"}],"b":null}}`,
+		},
+		{
+			"fragments",
+			Request{Query: `{ table(date: "2015-11-25") { ...Number ... on Table { date } ... on Currency { code } } }
+				fragment Number on Table { number ...Number }`},
+			`{"data":{"table":{"number":"229/A/NBP/2015","date":"2015-11-25"}}}`,
+		},
+		{
+			"merged fields",
+			Request{Query: `{ table(date: "2015-11-25") { date } ... { table(date: "2015-11-25") { number } } }`},
+			`{"data":{"table":{"date":"2015-11-25","number":"229/A/NBP/2015"}}}`,
+		},
+		{
+			"skip and include",
+			Request{
+				Query: `query ($yes: Boolean!) { table(date: "2015-11-25") {
+					a: date @skip(if: true) b: date @skip(if: false) c: date @include(if: $yes) d: date @include(if: false)
+					... @skip(if: $yes) { number } ...N @include(if: $yes) } }
+					fragment N on Table { n: number }`,
+				Variables: map[string]interface{}{"yes": true},
+			},
+			`{"data":{"table":{"b":"2015-11-25","c":"2015-11-25","n":"229/A/NBP/2015"}}}`,
+		},
+		{
+			"operation name",
+			Request{Query: `query A { echo(value: "a") } query B { echo(value: "b") }`, OperationName: "B"},
+			`{"data":{"echo":"b"}}`,
+		},
+		{
+			"resolver error",
+			Request{Query: `{ table(date: "2015-11-26") { date } echo(value: 1) }`},
+			`{"data":{"table":null,"echo":1},"errors":[{"message":"Table not found","path":["table"]}]}`,
+		},
+		{
+			"nested error",
+			Request{Query: `{ table(date: "2015-11-25") { currencies(code: "XXX") { code } } }`},
+			`{"data":{"table":{"currencies":null}},"errors":[{"message":"Currency not found","path":["table","currencies"]}]}`,
+		},
+		{
+			"unknown field",
+			Request{Query: `{ table(date: "2015-11-25") { rate } }`},
+			`{"data":{"table":{"rate":null}},"errors":[{"message":"Cannot query field \"rate\" on type \"Table\"","path":["table","rate"]}]}`,
+		},
+		{
+			"unknown argument",
+			Request{Query: `{ echo(other: 1) }`},
+			`{"data":{"echo":null},"errors":[{"message":"Unknown argument \"other\" on field \"echo\"","path":["echo"]}]}`,
+		},
+		{
+			"missing argument",
+			Request{Query: `{ table { date } }`},
+			`{"data":{"table":null},"errors":[{"message":"Argument \"date\" of field \"table\" is required","path":["table"]}]}`,
+		},
+		{
+			"selection of scalar",
+			Request{Query: `{ echo { x } }`},
+			`{"data":{"echo":null},"errors":[{"message":"Field \"echo\" must not have a selection since it's a scalar","path":["echo"]}]}`,
+		},
+		{
+			"no selection of object",
+			Request{Query: `{ table(date: "2015-11-25") }`},
+			`{"data":{"table":null},"errors":[{"message":"Field \"table\" of type \"Table\" must have a selection of subfields","path":["table"]}]}`,
+		},
+		{
+			"syntax error",
+			Request{Query: `{ table(date: "2015-11-25") { date }`},
+			`{"data":null,"errors":[{"message":"Syntax error at 1:37: unexpected end of document"}]}`,
+		},
+		{
+			"missing variable",
+			Request{Query: `query ($date: String!) { table(date: $date) { date } }`},
+			`{"data":null,"errors":[{"message":"Variable $date of required type was not provided"}]}`,
+		},
+		{
+			"operation name required",
+			Request{Query: `query A { echo } query B { echo }`},
+			`{"data":null,"errors":[{"message":"Operation name is required when the document has more operations"}]}`,
+		},
+		{
+			"unknown operation",
+			Request{Query: `query A { echo }`, OperationName: "B"},
+			`{"data":null,"errors":[{"message":"Unknown operation \"B\""}]}`,
+		},
+		{
+			"mutation",
+			Request{Query: `mutation { echo }`},
+			`{"data":null,"errors":[{"message":"Only queries are supported"}]}`,
+		},
+		{
+			"too many fields",
+			Request{Query: `{ a: echo b: echo ... { c: echo d: echo } }`},
+			`{"data":null,"errors":[{"message":"Query selects 4 fields, at most 3 are allowed"}]}`,
+		},
+		{
+			"skipped fields don't count",
+			Request{Query: `{ a: echo b: echo c: echo d: echo @skip(if: true) }`},
+			`{"data":{"a":null,"b":null,"c":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(testSchema.Execute(tt.req))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, b, tt.want)
+		}
+	}
+}
+
+func TestExecuteAdmit(t *testing.T) {
+	var admitted []int
+	admit := func(fields int) error {
+		admitted = append(admitted, fields)
+		if fields > 2 {
+			return errors.New("Too many requests")
+		}
+		return nil
+	}
+
+	b, _ := json.Marshal(testSchema.Execute(Request{Query: `{ a: echo b: echo a: echo }`, Admit: admit}))
+	if want := `{"data":{"a":null,"b":null}}`; string(b) != want {
+		t.Errorf("admitted query:\n got %s\nwant %s", b, want)
+	}
+
+	resolved := false
+	schema := &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"echo": {Resolve: func(p Params) (interface{}, error) {
+			resolved = true
+			return nil, nil
+		}},
+	}}}
+	b, _ = json.Marshal(schema.Execute(Request{Query: `{ a: echo b: echo c: echo }`, Admit: admit}))
+	if want := `{"data":null,"errors":[{"message":"Too many requests"}]}`; string(b) != want {
+		t.Errorf("rejected query:\n got %s\nwant %s", b, want)
+	}
+	if resolved {
+		t.Error("field of rejected query was resolved")
+	}
+	if len(admitted) != 2 || admitted[0] != 2 || admitted[1] != 3 {
+		t.Errorf("Admit was given %v, want [2 3]", admitted)
+	}
+}
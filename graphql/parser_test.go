@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# comments and commas are ignored
+		query Rates($date: String! = "2015-11-25", $codes: [String!], $all: Boolean) @cached {
+			a: table(date: $date, type: both, codes: ["USD", "EUR"], limit: -1, scale: 1.5e2, on: true, off: null, opt: {x: 1}) {
+				date,
+				...Cur @include(if: $all)
+				... on Table { number }
+				... @skip(if: false) { type }
+			}
+		}
+		fragment Cur on Table { currencies { code } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 || len(doc.fragments) != 1 {
+		t.Fatalf("parsed %d operations and %d fragments, want 1 and 1", len(doc.operations), len(doc.fragments))
+	}
+
+	op := doc.operations[0]
+	if op.typ != "query" || op.name != "Rates" || len(op.directives) != 1 || op.directives[0].name != "cached" {
+		t.Errorf("operation = %s %s %v", op.typ, op.name, op.directives)
+	}
+	wantVars := []varDef{
+		{name: "date", typ: typeRef{name: "String", nonNull: true}, def: "2015-11-25", hasDefault: true},
+		{name: "codes", typ: typeRef{elem: &typeRef{name: "String", nonNull: true}}},
+		{name: "all", typ: typeRef{name: "Boolean"}},
+	}
+	if !reflect.DeepEqual(op.vars, wantVars) {
+		t.Errorf("variables = %+v, want %+v", op.vars, wantVars)
+	}
+
+	f := op.sel[0].(*field)
+	if f.alias != "a" || f.name != "table" {
+		t.Errorf("field = %s: %s, want a: table", f.alias, f.name)
+	}
+	wantArgs := []argument{
+		{"date", variable("date")},
+		{"type", enum("both")},
+		{"codes", []interface{}{"USD", "EUR"}},
+		{"limit", -1},
+		{"scale", 150.0},
+		{"on", true},
+		{"off", nil},
+		{"opt", []argument{{"x", 1}}},
+	}
+	if !reflect.DeepEqual(f.args, wantArgs) {
+		t.Errorf("arguments = %#v, want %#v", f.args, wantArgs)
+	}
+
+	if len(f.sel) != 4 {
+		t.Fatalf("parsed %d selections, want 4", len(f.sel))
+	}
+	if s, ok := f.sel[1].(*fragmentSpread); !ok || s.name != "Cur" || s.directives[0].name != "include" {
+		t.Errorf("selection 1 = %#v, want ...Cur @include", f.sel[1])
+	}
+	if s, ok := f.sel[2].(*inlineFragment); !ok || s.typeCond != "Table" {
+		t.Errorf("selection 2 = %#v, want ... on Table", f.sel[2])
+	}
+	if s, ok := f.sel[3].(*inlineFragment); !ok || s.typeCond != "" || s.directives[0].name != "skip" {
+		t.Errorf("selection 3 = %#v, want ... @skip", f.sel[3])
+	}
+	if frag := doc.fragments["Cur"]; frag.typeCond != "Table" || len(frag.sel) != 1 {
+		t.Errorf("fragment = %+v", frag)
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`"złoty"`, "złoty"},
+		{`"z\u0142oty"`, "złoty"},
+		{`"a\"b\\c\/d\n\t"`, "a\"b\\c/d\n\t"},
+		{`"""block "quoted"
+text"""`, "block \"quoted\"\ntext"},
+	}
+	for _, tt := range tests {
+		doc, err := parse(`{ table(date: ` + tt.src + `) { date } }`)
+		if err != nil {
+			t.Errorf("parse(%s) failed: %v", tt.src, err)
+			continue
+		}
+		if got := doc.operations[0].sel[0].(*field).args[0].value; got != tt.want {
+			t.Errorf("parse(%s) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{``, "Document has no operations"},
+		{`fragment F on Table { date }`, "Document has no operations"},
+		{`{`, "1:2: unexpected end of document"},
+		{`{}`, `1:2: unexpected "}"`},
+		{`{ table(date: "2015-11-25" }`, `unexpected "}"`},
+		{`{ table(date: "2015-11-25) { date } }`, "unterminated string"},
+		{`{ table(date: "\x") { date } }`, `invalid escape \x`},
+		{`{ table(date: "\u12") { date } }`, "invalid unicode escape"},
+		{`{ table(date: 1.) { date } }`, "invalid number"},
+		{`{ table(date: 99999999999999999999) { date } }`, "invalid integer"},
+		{`{ table ~ }`, `unexpected character '~'`},
+		{`query Q($d String) { table(date: $d) { date } }`, `unexpected "String"`},
+		{`query Q($d: String = $e) { table(date: $d) { date } }`, `unexpected "$"`},
+		{`fragment F Table { date } { table { date } }`, `expected "on"`},
+		{`mutation { x }` + "\n" + `{ table(date: "2015-11-25") { date } } }`, `2:40: unexpected "}"`},
+		{`subscription`, "unexpected end of document"},
+		{`schema { query: Query }`, `unexpected "schema"`},
+	}
+	for _, tt := range tests {
+		_, err := parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parse(%q) = %v, want error with %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		name string
+		src  func(n int) string
+	}{
+		{"selections", func(n int) string { return strings.Repeat("{ a ", n) + strings.Repeat("}", n) }},
+		{"lists", func(n int) string { return "{ a(x: " + strings.Repeat("[", n) + strings.Repeat("]", n) + ") }" }},
+		{"objects", func(n int) string {
+			return "{ a(x: " + strings.Repeat("{y: ", n) + "1" + strings.Repeat("}", n) + ") }"
+		}},
+		{"types", func(n int) string {
+			return "query($x: " + strings.Repeat("[", n) + "Int" + strings.Repeat("]", n) + ") { a }"
+		}},
+	}
+	for _, tt := range tests {
+		// The selection set of the operation and the argument take a level each
+		if _, err := parse(tt.src(maxDepth - 1)); err != nil {
+			t.Errorf("%s: parse at the limit failed: %v", tt.name, err)
+		}
+		_, err := parse(tt.src(maxDepth + 1))
+		if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+			t.Errorf("%s: parse over the limit = %v, want nested too deeply", tt.name, err)
+		}
+	}
+
+	// Deep enough to exhaust the stack without the limit
+	if _, err := parse(strings.Repeat("{ a ", 1000000)); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("parse of a million levels = %v, want nested too deeply", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package limit limits the rate of the requests with token buckets.
+package limit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate allows N requests per period. A client can use all of them at once, the tokens are refilled evenly.
+// The zero Rate is unlimited.
+type Rate struct {
+	N   int
+	Per time.Duration
+}
+
+// ParseRate parses the rate written as N/period, e.g. "60/1m". An empty string is unlimited.
+func ParseRate(s string) (Rate, error) {
+	if s == "" {
+		return Rate{}, nil
+	}
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return Rate{}, errors.New("Couldn't parse the rate " + s + ", use N/period, e.g. 60/1m")
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n <= 0 {
+		return Rate{}, errors.New("Couldn't parse the rate " + s + ", use N/period, e.g. 60/1m")
+	}
+	per, err := time.ParseDuration(s[i+1:])
+	if err != nil || per <= 0 {
+		return Rate{}, errors.New("Couldn't parse the rate " + s + ", use N/period, e.g. 60/1m")
+	}
+	return Rate{N: n, Per: per}, nil
+}
+
+// Unlimited reports whether the rate doesn't limit the requests.
+func (r Rate) Unlimited() bool {
+	return r.N == 0
+}
+
+// sweepInterval is how often the buckets not used for long are removed.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   Rate
+}
+
+// Limiter keeps a token bucket per client. It's safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// New returns the limiter without any buckets.
+func New() *Limiter {
+	return &Limiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the client, created with the rate on the first request.
+// When the bucket is empty, it returns false and how long to wait for the next token.
+func (l *Limiter) Allow(client string, r Rate) (bool, time.Duration) {
+	return l.AllowN(client, r, 1)
+}
+
+// AllowN takes n tokens from the bucket of the client, for a request costing as much as n ones.
+// When the bucket doesn't have them, it returns false and how long to wait until it has. No tokens are taken then.
+// More than N tokens are never allowed, and the wait is 0.
+func (l *Limiter) AllowN(client string, r Rate, n int) (bool, time.Duration) {
+	if r.Unlimited() {
+		return true, 0
+	}
+	if n > r.N {
+		return false, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok || b.rate != r {
+		b = &bucket{tokens: float64(r.N), last: now, rate: r}
+		l.buckets[client] = b
+	}
+
+	// Refill the tokens for the time since the last request
+	perToken := float64(r.Per) / float64(r.N)
+	b.tokens += float64(now.Sub(b.last)) / perToken
+	if b.tokens > float64(r.N) {
+		b.tokens = float64(r.N)
+	}
+	b.last = now
+
+	if b.tokens < float64(n) {
+		return false, time.Duration((float64(n) - b.tokens) * perToken)
+	}
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// sweep removes the buckets which got full since their last use, they're the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= b.rate.Per {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+		ok   bool
+	}{
+		{"", Rate{}, true},
+		{"60/1m", Rate{N: 60, Per: time.Minute}, true},
+		{"1000/24h", Rate{N: 1000, Per: 24 * time.Hour}, true},
+		{"5/1.5s", Rate{N: 5, Per: 1500 * time.Millisecond}, true},
+		{"60", Rate{}, false},
+		{"x/1m", Rate{}, false},
+		{"0/1m", Rate{}, false},
+		{"-1/1m", Rate{}, false},
+		{"60/", Rate{}, false},
+		{"60/0s", Rate{}, false},
+		{"60/m", Rate{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v, want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// clock is the time of the limiter, moved by the tests.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newLimiter() (*Limiter, *clock) {
+	c := &clock{t: time.Date(2015, 11, 25, 12, 0, 0, 0, time.UTC)}
+	l := New()
+	l.now = c.now
+	return l, c
+}
+
+func TestAllow(t *testing.T) {
+	rate := Rate{N: 3, Per: 3 * time.Second}
+	tests := []struct {
+		after time.Duration
+		ok    bool
+		wait  time.Duration
+	}{
+		// The full bucket allows a burst
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, time.Second},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		// A token is refilled every second
+		{500 * time.Millisecond, true, 0},
+		{0, false, time.Second},
+		// The bucket doesn't hold more tokens than N
+		{time.Hour, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, time.Second},
+	}
+	l, c := newLimiter()
+	for i, tt := range tests {
+		c.t = c.t.Add(tt.after)
+		ok, wait := l.Allow("client", rate)
+		if ok != tt.ok || wait != tt.wait {
+			t.Errorf("%d: Allow = %v, %v, want %v, %v", i, ok, wait, tt.ok, tt.wait)
+		}
+	}
+}
+
+func TestAllowN(t *testing.T) {
+	rate := Rate{N: 10, Per: 10 * time.Second}
+	tests := []struct {
+		after time.Duration
+		n     int
+		ok    bool
+		wait  time.Duration
+	}{
+		{0, 6, true, 0},
+		// The tokens aren't taken when there aren't enough of them
+		{0, 5, false, time.Second},
+		{0, 4, true, 0},
+		{0, 1, false, time.Second},
+		{3 * time.Second, 3, true, 0},
+		// More than the bucket holds is never allowed
+		{time.Hour, 11, false, 0},
+		{0, 10, true, 0},
+		{0, 0, true, 0},
+	}
+	l, c := newLimiter()
+	for i, tt := range tests {
+		c.t = c.t.Add(tt.after)
+		ok, wait := l.AllowN("client", rate, tt.n)
+		if ok != tt.ok || wait != tt.wait {
+			t.Errorf("%d: AllowN(%d) = %v, %v, want %v, %v", i, tt.n, ok, wait, tt.ok, tt.wait)
+		}
+	}
+}
+
+func TestAllowPerClient(t *testing.T) {
+	rate := Rate{N: 1, Per: time.Minute}
+	l, _ := newLimiter()
+	if ok, _ := l.Allow("a", rate); !ok {
+		t.Error("first request of a was limited")
+	}
+	if ok, _ := l.Allow("a", rate); ok {
+		t.Error("second request of a was allowed")
+	}
+	if ok, _ := l.Allow("b", rate); !ok {
+		t.Error("request of b was limited by the bucket of a")
+	}
+	// A new rate of the client starts with the full bucket
+	if ok, _ := l.Allow("a", Rate{N: 2, Per: time.Minute}); !ok {
+		t.Error("request of a with a new rate was limited")
+	}
+}
+
+func TestAllowUnlimited(t *testing.T) {
+	l, _ := newLimiter()
+	for i := 0; i < 1000; i++ {
+		if ok, _ := l.Allow("client", Rate{}); !ok {
+			t.Fatal("unlimited rate limited the request")
+		}
+	}
+	if len(l.buckets) != 0 {
+		t.Error("unlimited rate created a bucket")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	rate := Rate{N: 1, Per: time.Second}
+	l, c := newLimiter()
+	l.Allow("a", rate)
+	c.t = c.t.Add(2 * sweepInterval)
+	l.Allow("b", rate)
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("the bucket full again wasn't removed")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("the bucket in use was removed")
+	}
+}
@@ -4,11 +4,146 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/karolgorecki/nbp/limit"
+	"github.com/karolgorecki/nbp/poller"
+	"github.com/karolgorecki/nbp/rpc"
 	"github.com/karolgorecki/nbp/server"
+	"github.com/karolgorecki/nbp/store"
+	"github.com/karolgorecki/nbp/svc"
+	"github.com/karolgorecki/nbp/webhook"
 )
 
 func main() {
-	rt := server.RegisterHandlers()
+	b, err := svc.NewBackend(os.Getenv("NBP_BACKEND"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var g svc.GoldBackend = svc.APIGoldBackend{}
+
+	// Persist the tables and the gold prices fetched from NBP, if the store file is given
+	if path := os.Getenv("NBP_STORE"); path != "" {
+		s, err := store.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b = store.Backend{Store: s, Backend: b}
+		g = store.GoldBackend{Store: s, Backend: g}
+	}
+
+	c := server.Config{
+		Backend:       b,
+		Gold:          g,
+		AdminToken:    os.Getenv("NBP_ADMIN_TOKEN"),
+		RequireAPIKey: os.Getenv("NBP_REQUIRE_API_KEY") != "",
+		TrustProxy:    os.Getenv("NBP_TRUST_PROXY") != "",
+	}
+
+	// Allow the cross-origin requests from any origin, unless the origins are given
+	origins, ok := os.LookupEnv("NBP_CORS_ORIGINS")
+	if !ok {
+		origins = "*"
+	}
+	c.CORS = server.CORS{
+		AllowedOrigins:   list(origins),
+		AllowedMethods:   list(os.Getenv("NBP_CORS_METHODS")),
+		AllowedHeaders:   list(os.Getenv("NBP_CORS_HEADERS")),
+		AllowCredentials: os.Getenv("NBP_CORS_CREDENTIALS") != "",
+	}
+	if s := os.Getenv("NBP_CORS_MAX_AGE"); s != "" {
+		if c.CORS.MaxAge, err = time.ParseDuration(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := c.CORS.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Limit the rate of the requests per API key and per client IP
+	if c.IPRate, err = limit.ParseRate(os.Getenv("NBP_IP_RATE_LIMIT")); err != nil {
+		log.Fatal(err)
+	}
+	if c.APIKeys, err = apiKeys(os.Getenv("NBP_API_KEYS"), os.Getenv("NBP_KEY_RATE_LIMIT")); err != nil {
+		log.Fatal(err)
+	}
+
+	// Watch for new tables, if they're streamed or sent to the webhooks
+	webhooks := os.Getenv("NBP_WEBHOOKS")
+	if webhooks != "" || os.Getenv("NBP_STREAM") != "" {
+		interval := 5 * time.Minute
+		if s := os.Getenv("NBP_POLL_INTERVAL"); s != "" {
+			if interval, err = time.ParseDuration(s); err != nil {
+				log.Fatal(err)
+			}
+		}
+		c.Publications = poller.New(interval, b)
+	}
+
+	// Notify the webhooks about new tables, if the webhooks file is given
+	if webhooks != "" {
+		if c.AdminToken == "" {
+			log.Fatal("NBP_WEBHOOKS requires NBP_ADMIN_TOKEN, otherwise anyone could make the server send requests to any URL")
+		}
+		c.Webhooks, err = webhook.Open(webhooks)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pubs, _ := c.Publications.Subscribe()
+		go webhook.NewNotifier(c.Webhooks).Run(pubs)
+	}
+
+	if c.Publications != nil {
+		go c.Publications.Run()
+	}
+
+	// Serve gRPC alongside, if the port is given
+	if port := os.Getenv("NBP_GRPC_PORT"); port != "" {
+		go func() {
+			log.Fatal(rpc.ListenAndServe(":"+port, b))
+		}()
+	}
+
+	rt := server.RegisterHandlers(c)
 	log.Fatal(http.ListenAndServe(":"+os.Getenv("PORT"), rt))
 }
+
+// apiKeys parses the comma separated API keys. A key can have its own rate limit after colon, e.g. "key1:600/1m,key2",
+// otherwise the default one is used.
+func apiKeys(keys string, defaultRate string) (map[string]limit.Rate, error) {
+	def, err := limit.ParseRate(defaultRate)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]limit.Rate{}
+	for _, k := range strings.Split(keys, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		rate := def
+		if i := strings.Index(k, ":"); i >= 0 {
+			if rate, err = limit.ParseRate(k[i+1:]); err != nil {
+				return nil, err
+			}
+			k = k[:i]
+		}
+		res[k] = rate
+	}
+	return res, nil
+}
+
+// list returns the items of the comma separated list.
+func list(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+// Package poller watches the NBP dir index for newly published tables.
+package poller
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// historySize is the number of the last publications kept by the poller.
+const historySize = 100
+
+// types maps the letters of the tables the poller watches to their types.
+var types = map[string]string{"a": "avg", "c": "both"}
+
+// Publication is a newly published table.
+type Publication struct {
+	// Type is the type of the table: "avg" or "both".
+	Type  string    `json:"type"`
+	File  string    `json:"file"`
+	Table svc.Query `json:"table"`
+}
+
+// Poller checks the index of the current year in given interval
+// and sends the tables which weren't listed before, fetched from the backend, to the subscribers.
+type Poller struct {
+	Interval time.Duration
+	Backend  svc.Backend
+	// Index lists the tables published in the year, it's svc.GetIndex unless the tests list their own.
+	Index func(year int) ([]svc.Entry, error)
+
+	mu      sync.Mutex
+	seen    map[string]bool
+	subs    map[chan Publication]bool
+	history []Publication
+}
+
+// New returns the poller checking the index in given interval and fetching the new tables from b.
+func New(interval time.Duration, b svc.Backend) *Poller {
+	return &Poller{
+		Interval: interval,
+		Backend:  b,
+		Index:    svc.GetIndex,
+		subs:     map[chan Publication]bool{},
+	}
+}
+
+// Run polls the index until the program exits.
+func (p *Poller) Run() {
+	for {
+		if err := p.Poll(); err != nil {
+			log.Println(err)
+		}
+		time.Sleep(p.Interval)
+	}
+}
+
+// Poll checks the index once. The tables listed at the first check are only remembered,
+// only the ones published after it are sent to the subscribers.
+func (p *Poller) Poll() error {
+	entries, err := p.Index(calendar.Today().Year())
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	first := p.seen == nil
+	if first {
+		p.seen = map[string]bool{}
+	}
+	var fresh []svc.Entry
+	for _, e := range entries {
+		if p.seen[e.File] {
+			continue
+		}
+		if first || types[e.Type] == "" {
+			p.seen[e.File] = true
+			continue
+		}
+		fresh = append(fresh, e)
+	}
+	p.mu.Unlock()
+
+	for _, e := range fresh {
+		q, err := p.Backend.Table(e.Date, types[e.Type], "*")
+		if err != nil {
+			// The table will be fetched again at the next check
+			return err
+		}
+
+		p.mu.Lock()
+		p.seen[e.File] = true
+		p.mu.Unlock()
+
+		p.publish(Publication{Type: types[e.Type], File: e.File, Table: q})
+	}
+	return nil
+}
+
+// Subscribe returns the channel the new publications are sent to and the function cancelling the subscription.
+// The subscriber has to read the channel promptly, the publications are dropped when its buffer is full.
+func (p *Poller) Subscribe() (<-chan Publication, func()) {
+	ch := make(chan Publication, 16)
+
+	p.mu.Lock()
+	p.subs[ch] = true
+	p.mu.Unlock()
+
+	cancel := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.subs[ch] {
+			delete(p.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Since returns the publications which came after the table with given number, oldest first.
+// When the table is not among the last publications, all publications the poller keeps are returned.
+func (p *Poller) Since(tableNumber string) []Publication {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.history) - 1; i >= 0; i-- {
+		if p.history[i].Table.TableNumber == tableNumber {
+			return append([]Publication{}, p.history[i+1:]...)
+		}
+	}
+	return append([]Publication{}, p.history...)
+}
+
+func (p *Poller) publish(pub Publication) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.history = append(p.history, pub)
+	if len(p.history) > historySize {
+		p.history = p.history[len(p.history)-historySize:]
+	}
+
+	for ch := range p.subs {
+		select {
+		case ch <- pub:
+		default:
+			log.Println("poller: subscriber too slow, dropped " + pub.File)
+		}
+	}
+}
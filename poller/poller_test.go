@@ -0,0 +1,194 @@
+package poller
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// stubBackend returns the tables of the entries, unless their files are failing.
+type stubBackend struct {
+	failing map[string]bool
+}
+
+func (b stubBackend) Table(date time.Time, sType string, code string) (svc.Query, error) {
+	file := fileOf(sType, date)
+	if b.failing[file] {
+		return svc.Query{}, errors.New("NBP is down")
+	}
+	return svc.Query{FromData: date.Format("2006-01-02"), TableNumber: file}, nil
+}
+
+func (b stubBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]svc.Query, error) {
+	return nil, errors.New("not used")
+}
+
+// fileOf returns the file of the table like in the index, e.g. a229z151125 for the table A of 2015-11-25.
+// The number of the table is the day of the year, which is enough for the tests.
+func fileOf(sType string, date time.Time) string {
+	letter := "a"
+	if sType == "both" {
+		letter = "c"
+	}
+	return fmt.Sprintf("%s%03dz%s", letter, date.YearDay(), date.Format("060102"))
+}
+
+// entries returns the index entries of the tables of given letters published on the dates.
+func entries(letters string, dates ...string) []svc.Entry {
+	var res []svc.Entry
+	for _, d := range dates {
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			panic(err)
+		}
+		for _, l := range letters {
+			sType := types[string(l)]
+			file := fileOf(sType, date)
+			if sType == "" {
+				file = fmt.Sprintf("%c%03dz%s", l, date.YearDay(), date.Format("060102"))
+			}
+			res = append(res, svc.Entry{File: file, Type: string(l), Number: date.YearDay(), Date: date})
+		}
+	}
+	return res
+}
+
+// stubIndex makes the poller see the entries index points to.
+func stubIndex(p *Poller, index *[]svc.Entry) {
+	p.Index = func(year int) ([]svc.Entry, error) {
+		return *index, nil
+	}
+}
+
+// received returns the files of the publications waiting in the channel.
+func received(ch <-chan Publication) []string {
+	var res []string
+	for {
+		select {
+		case pub := <-ch:
+			res = append(res, pub.File)
+		default:
+			return res
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	var index []svc.Entry
+	b := stubBackend{failing: map[string]bool{}}
+	p := New(time.Minute, b)
+	stubIndex(p, &index)
+	ch, cancel := p.Subscribe()
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		index   []svc.Entry
+		failing string
+		err     bool
+		want    []string
+	}{
+		// The tables listed at the first check aren't new
+		{"first", entries("abch", "2015-11-24"), "", false, nil},
+		{"nothing new", entries("abch", "2015-11-24"), "", false, nil},
+		// Only the tables A and C are watched
+		{"new", entries("abch", "2015-11-24", "2015-11-25"), "", false, []string{"a329z151125", "c329z151125"}},
+		// The table failing to be fetched is sent at the next check
+		{"failing", entries("abch", "2015-11-24", "2015-11-25", "2015-11-26"), "c330z151126", true, []string{"a330z151126"}},
+		{"retried", entries("abch", "2015-11-24", "2015-11-25", "2015-11-26"), "", false, []string{"c330z151126"}},
+	}
+
+	for _, tt := range tests {
+		index = tt.index
+		b.failing[tt.failing] = true
+		err := p.Poll()
+		delete(b.failing, tt.failing)
+
+		if (err != nil) != tt.err {
+			t.Errorf("%s: Poll() error = %v", tt.name, err)
+		}
+		if got := received(ch); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: published %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublication(t *testing.T) {
+	var index []svc.Entry
+	p := New(time.Minute, stubBackend{})
+	stubIndex(p, &index)
+	if err := p.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	index = entries("a", "2015-11-25")
+	if err := p.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Publication{Type: "avg", File: "a329z151125", Table: svc.Query{FromData: "2015-11-25", TableNumber: "a329z151125"}}
+	if got := p.Since(""); !reflect.DeepEqual(got, []Publication{want}) {
+		t.Errorf("Since() = %+v, want %+v", got, []Publication{want})
+	}
+}
+
+func TestSince(t *testing.T) {
+	p := New(time.Minute, stubBackend{})
+	for i := 1; i <= historySize+10; i++ {
+		p.publish(Publication{File: fmt.Sprint(i), Table: svc.Query{TableNumber: fmt.Sprintf("%d/A/NBP/2015", i)}})
+	}
+
+	tests := []struct {
+		number string
+		// first and n are the first publication returned and how many of them
+		first string
+		n     int
+	}{
+		{"105/A/NBP/2015", "106", 5},
+		{"110/A/NBP/2015", "", 0},
+		// The older publications aren't kept, so all are returned
+		{"5/A/NBP/2015", "11", historySize},
+		{"", "11", historySize},
+	}
+	for _, tt := range tests {
+		got := p.Since(tt.number)
+		if len(got) != tt.n || len(got) > 0 && got[0].File != tt.first {
+			t.Errorf("Since(%q) returned %d starting with %+v, want %d starting with %s", tt.number, len(got), got, tt.n, tt.first)
+		}
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	p := New(time.Minute, stubBackend{})
+	slow, cancelSlow := p.Subscribe()
+	fast, cancelFast := p.Subscribe()
+
+	var got []string
+	for i := 0; i < 20; i++ {
+		p.publish(Publication{File: fmt.Sprint(i)})
+		got = append(got, received(fast)...)
+	}
+
+	// The slow subscriber gets what fits in its buffer, the rest is dropped
+	if n := len(received(slow)); n != cap(slow) {
+		t.Errorf("slow subscriber got %d publications, want %d", n, cap(slow))
+	}
+	if len(got) != 20 {
+		t.Errorf("fast subscriber got %d publications, want 20", len(got))
+	}
+
+	// Cancelling closes the channel and stops the publications
+	cancelSlow()
+	cancelSlow()
+	if _, ok := <-slow; ok {
+		t.Error("channel not closed after cancel")
+	}
+	p.publish(Publication{File: "after"})
+	if got := received(fast); !reflect.DeepEqual(got, []string{"after"}) {
+		t.Errorf("fast subscriber got %v after the other one cancelled", got)
+	}
+	cancelFast()
+}
@@ -0,0 +1,197 @@
+package rpc
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// Table types, as TableType enum in nbp.proto.
+const (
+	typeUnspecified = 0
+	typeAvg         = 1
+	typeBoth        = 2
+)
+
+// tableRequest is TableRequest, LatestTableRequest or RangeRequest message.
+// The field numbers differ between them, so they're given to decode.
+type tableRequest struct {
+	Date  string
+	From  string
+	To    string
+	Type  int
+	Codes []string
+}
+
+// fields maps the field numbers of a request message to the fields of tableRequest.
+type fields struct {
+	date, from, to, typ, codes int
+}
+
+var (
+	tableRequestFields  = fields{date: 1, typ: 2, codes: 3}
+	latestRequestFields = fields{typ: 1, codes: 2}
+	rangeRequestFields  = fields{from: 1, to: 2, typ: 3, codes: 4}
+)
+
+func (r *tableRequest) decode(b []byte, f fields) error {
+	d := decoder{buf: b}
+	for {
+		num, wire, ok, err := d.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		var s *string
+		switch num {
+		case 0:
+			return errors.New("invalid field number")
+		case f.date:
+			s = &r.Date
+		case f.from:
+			s = &r.From
+		case f.to:
+			s = &r.To
+		case f.typ:
+			if wire != wireVarint {
+				return errors.New("invalid type field")
+			}
+			v, err := d.varint()
+			if err != nil {
+				return err
+			}
+			r.Type = int(v)
+			continue
+		case f.codes:
+			if wire != wireBytes {
+				return errors.New("invalid codes field")
+			}
+			b, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			r.Codes = append(r.Codes, string(b))
+			continue
+		default:
+			if err := d.skip(wire); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if wire != wireBytes {
+			return errors.New("invalid string field")
+		}
+		b, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		*s = string(b)
+	}
+}
+
+// tableType returns the type as svc expects it.
+func (r *tableRequest) tableType() (string, error) {
+	switch r.Type {
+	case typeUnspecified, typeAvg:
+		return "avg", nil
+	case typeBoth:
+		return "both", nil
+	}
+	return "", errors.New("Given type is wrong. Use TABLE_TYPE_AVG or TABLE_TYPE_BOTH")
+}
+
+// codes returns the codes as svc expects them, "*" for all.
+func (r *tableRequest) codes() string {
+	if len(r.Codes) == 0 {
+		return "*"
+	}
+	return strings.ToUpper(strings.Join(r.Codes, ","))
+}
+
+// encodeTable encodes the table as Table message.
+func encodeTable(q svc.Query, rType string, notice string) []byte {
+	e := encoder{}
+	e.string(1, q.FromData)
+	e.string(2, q.TableNumber)
+	if rType == "both" {
+		q = svc.Spreads(q)
+		e.int64(3, typeBoth)
+	} else {
+		e.int64(3, typeAvg)
+	}
+	for _, c := range q.Currencies {
+		ce := encoder{}
+		ce.string(1, c.Code)
+		ce.string(2, c.Name)
+		ce.int64(3, ratio(c.Ratio))
+		if c.Average != "" {
+			ce.message(4, encodeDecimal(c.Average))
+		}
+		if c.Buy != "" {
+			ce.message(5, encodeDecimal(c.Buy))
+		}
+		if c.Sell != "" {
+			ce.message(6, encodeDecimal(c.Sell))
+		}
+		if c.Mid != "" {
+			ce.message(7, encodeDecimal(c.Mid))
+			ce.message(8, encodeDecimal(c.Spread))
+			ce.message(9, encodeDecimal(c.SpreadPercent))
+		}
+		e.message(4, ce.buf)
+	}
+	e.string(5, notice)
+	return e.buf
+}
+
+// encodeDecimal encodes the NBP number, e.g. "3,9860", as Decimal message {value: "3.9860", units: 39860, scale: 4}.
+func encodeDecimal(s string) []byte {
+	value := strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	e := encoder{}
+	e.string(1, value)
+
+	scale := 0
+	if i := strings.Index(value, "."); i >= 0 {
+		scale = len(value) - i - 1
+	}
+	if r, err := svc.ParseDecimal(value); err == nil {
+		if units, ok := scaled(r.FloatString(scale)); ok {
+			e.int64(2, units)
+			e.int64(3, int64(scale))
+		}
+	}
+	return e.buf
+}
+
+// scaled returns the decimal number without the decimal point as an integer, e.g. 39860 for "3.9860".
+func scaled(s string) (int64, bool) {
+	var n int64
+	neg := strings.HasPrefix(s, "-")
+	for _, c := range strings.TrimPrefix(s, "-") {
+		if c == '.' {
+			continue
+		}
+		if c < '0' || c > '9' || n > (1<<63-1)/10-1 {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// ratio returns the ratio (przelicznik) as a number, 1 when it's missing.
+func ratio(s string) int64 {
+	n, ok := scaled(strings.TrimSpace(s))
+	if !ok || n == 0 || strings.ContainsAny(s, ".,") {
+		return 1
+	}
+	return n
+}
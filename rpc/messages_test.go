@@ -0,0 +1,327 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// protoFields returns the field numbers of the messages and the values of the enums declared in nbp.proto, by name.
+func protoFields(t *testing.T) map[string]map[string]int {
+	src, err := ioutil.ReadFile("nbp.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := regexp.MustCompile(`^(message|enum) (\w+) \{$`)
+	field := regexp.MustCompile(`^(?:repeated )?(?:\w+ )?(\w+) = (\d+);`)
+	res := map[string]map[string]int{}
+	var current map[string]int
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if m := block.FindStringSubmatch(line); m != nil {
+			current = map[string]int{}
+			res[m[2]] = current
+			continue
+		}
+		if line == "}" {
+			current = nil
+			continue
+		}
+		if m := field.FindStringSubmatch(line); m != nil && current != nil {
+			current[m[1]], _ = strconv.Atoi(m[2])
+		}
+	}
+	return res
+}
+
+func TestRequestFields(t *testing.T) {
+	proto := protoFields(t)
+	tests := []struct {
+		message string
+		fields  fields
+	}{
+		{"TableRequest", tableRequestFields},
+		{"LatestTableRequest", latestRequestFields},
+		{"RangeRequest", rangeRequestFields},
+	}
+	for _, tt := range tests {
+		got := map[string]int{}
+		for name, num := range map[string]int{"date": tt.fields.date, "from": tt.fields.from, "to": tt.fields.to, "type": tt.fields.typ, "codes": tt.fields.codes} {
+			if num != 0 {
+				got[name] = num
+			}
+		}
+		if !reflect.DeepEqual(got, proto[tt.message]) {
+			t.Errorf("%s fields = %v, nbp.proto has %v", tt.message, got, proto[tt.message])
+		}
+	}
+
+	types := proto["TableType"]
+	if types["TABLE_TYPE_UNSPECIFIED"] != typeUnspecified || types["TABLE_TYPE_AVG"] != typeAvg || types["TABLE_TYPE_BOTH"] != typeBoth {
+		t.Errorf("TableType = %v, nbp.proto has %v", []int{typeUnspecified, typeAvg, typeBoth}, types)
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	proto := protoFields(t)
+	tests := []struct {
+		message string
+		fields  fields
+		want    tableRequest
+	}{
+		{"TableRequest", tableRequestFields, tableRequest{Date: "2015-11-25", Type: typeBoth, Codes: []string{"USD", "eur"}}},
+		{"LatestTableRequest", latestRequestFields, tableRequest{Type: typeAvg, Codes: []string{"USD"}}},
+		{"RangeRequest", rangeRequestFields, tableRequest{From: "2015-11-01", To: "2015-11-30", Type: typeBoth}},
+		{"TableRequest", tableRequestFields, tableRequest{}},
+	}
+	for _, tt := range tests {
+		num := proto[tt.message]
+		e := encoder{}
+		e.string(num["date"], tt.want.Date)
+		e.string(num["from"], tt.want.From)
+		e.string(num["to"], tt.want.To)
+		// The fields of the newer versions of the message are skipped
+		e.tag(15, wireFixed32)
+		e.buf = append(e.buf, 1, 2, 3, 4)
+		e.int64(num["type"], int64(tt.want.Type))
+		for _, c := range tt.want.Codes {
+			e.string(num["codes"], c)
+		}
+		e.tag(16, wireFixed64)
+		e.buf = append(e.buf, 1, 2, 3, 4, 5, 6, 7, 8)
+		e.int64(17, 300)
+		e.string(18, "unknown")
+
+		var got tableRequest
+		if err := got.decode(e.buf, tt.fields); err != nil {
+			t.Errorf("%s: decode failed: %v", tt.message, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decode = %+v, want %+v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"truncated string", []byte{0x0a, 0x05, 'a'}},
+		{"truncated length", []byte{0x0a}},
+		{"string as varint", []byte{0x08, 0x01}},
+		{"type as string", []byte{0x12, 0x01, 'a'}},
+		{"codes as varint", []byte{0x18, 0x01}},
+		{"field zero", []byte{0x02, 0x00}},
+		{"truncated fixed32", []byte{0x7d, 1, 2}},
+		{"truncated fixed64", []byte{0x79, 1, 2, 3}},
+		{"group", []byte{0x7b}},
+		{"varint too long", append([]byte{0x10}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}...)},
+	}
+	for _, tt := range tests {
+		var r tableRequest
+		if err := r.decode(tt.msg, tableRequestFields); err == nil {
+			t.Errorf("%s: decode = %+v, want error", tt.name, r)
+		}
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<32 - 1, 1 << 63, 1<<64 - 1} {
+		e := encoder{}
+		e.varint(v)
+		d := decoder{buf: e.buf}
+		got, err := d.varint()
+		if err != nil || got != v || len(d.buf) != 0 {
+			t.Errorf("varint %d = %d, %v, %d bytes left", v, got, err, len(d.buf))
+		}
+	}
+
+	// Negative int64 take 10 bytes, as in Protocol Buffers
+	e := encoder{}
+	e.int64(1, -1)
+	if len(e.buf) != 11 {
+		t.Errorf("int64 -1 took %d bytes, want 11", len(e.buf))
+	}
+}
+
+// message is a decoded message: the values of its fields by number, raw bytes for strings and messages.
+type message map[int][]interface{}
+
+func decodeMessage(t *testing.T, b []byte) message {
+	m := message{}
+	d := decoder{buf: b}
+	for {
+		num, wire, ok, err := d.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return m
+		}
+		var v interface{}
+		switch wire {
+		case wireVarint:
+			v, err = d.varint()
+		case wireBytes:
+			v, err = d.bytes()
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", wire, num)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[num] = append(m[num], v)
+	}
+}
+
+func (m message) string(num int) string {
+	if len(m[num]) == 0 {
+		return ""
+	}
+	return string(m[num][0].([]byte))
+}
+
+func (m message) int64(num int) int64 {
+	if len(m[num]) == 0 {
+		return 0
+	}
+	return int64(m[num][0].(uint64))
+}
+
+// decimal is Decimal message.
+type decimal struct {
+	Value string
+	Units int64
+	Scale int64
+}
+
+func (m message) decimal(t *testing.T, num int, fields map[string]int) *decimal {
+	if len(m[num]) == 0 {
+		return nil
+	}
+	dm := decodeMessage(t, m[num][0].([]byte))
+	return &decimal{dm.string(fields["value"]), dm.int64(fields["units"]), dm.int64(fields["scale"])}
+}
+
+func TestEncodeTable(t *testing.T) {
+	proto := protoFields(t)
+	tf, cf, df := proto["Table"], proto["Currency"], proto["Decimal"]
+
+	var q svc.Query
+	err := json.Unmarshal([]byte(`{"fromDate":"2015-11-25","tableNumber":"229/C/NBP/2015","currencies":[
+		{"code":"USD","name":"dolar amerykański","ratio":"1","buy":"3,7907","sell":"3,8673"},
+		{"code":"HUF","name":"forint (Węgry)","ratio":"100","buy":"1,3652","sell":"1,3928"}]}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := decodeMessage(t, encodeTable(q, "both", "No table published on 2015-11-28: weekend"))
+	if got := m.string(tf["date"]); got != "2015-11-25" {
+		t.Errorf("date = %q", got)
+	}
+	if got := m.string(tf["number"]); got != "229/C/NBP/2015" {
+		t.Errorf("number = %q", got)
+	}
+	if got := m.int64(tf["type"]); got != typeBoth {
+		t.Errorf("type = %d, want %d", got, typeBoth)
+	}
+	if got := m.string(tf["notice"]); got != "No table published on 2015-11-28: weekend" {
+		t.Errorf("notice = %q", got)
+	}
+
+	type currency struct {
+		Code, Name                            string
+		Ratio                                 int64
+		Average, Buy, Sell, Mid, Spread, Perc *decimal
+	}
+	want := []currency{
+		{"USD", "dolar amerykański", 1, nil, &decimal{"3.7907", 37907, 4}, &decimal{"3.8673", 38673, 4},
+			&decimal{"3.82900", 382900, 5}, &decimal{"0.0766", 766, 4}, &decimal{"2.0005", 20005, 4}},
+		{"HUF", "forint (Węgry)", 100, nil, &decimal{"1.3652", 13652, 4}, &decimal{"1.3928", 13928, 4},
+			&decimal{"1.37900", 137900, 5}, &decimal{"0.0276", 276, 4}, &decimal{"2.0015", 20015, 4}},
+	}
+	var got []currency
+	for _, b := range m[tf["currencies"]] {
+		cm := decodeMessage(t, b.([]byte))
+		got = append(got, currency{
+			Code:    cm.string(cf["code"]),
+			Name:    cm.string(cf["name"]),
+			Ratio:   cm.int64(cf["ratio"]),
+			Average: cm.decimal(t, cf["average"], df),
+			Buy:     cm.decimal(t, cf["buy"], df),
+			Sell:    cm.decimal(t, cf["sell"], df),
+			Mid:     cm.decimal(t, cf["mid"], df),
+			Spread:  cm.decimal(t, cf["spread"], df),
+			Perc:    cm.decimal(t, cf["spread_percent"], df),
+		})
+	}
+	if !reflect.DeepEqual(got, want) {
+		gb, _ := json.Marshal(got)
+		wb, _ := json.Marshal(want)
+		t.Errorf("currencies = %s, want %s", gb, wb)
+	}
+
+	// Table A has the average rates only and the type omitted as the default isn't used
+	m = decodeMessage(t, encodeTable(q, "avg", ""))
+	if got := m.int64(tf["type"]); got != typeAvg {
+		t.Errorf("type of table A = %d, want %d", got, typeAvg)
+	}
+	if _, ok := m[tf["notice"]]; ok {
+		t.Error("empty notice was encoded")
+	}
+}
+
+func TestEncodeDecimal(t *testing.T) {
+	df := protoFields(t)["Decimal"]
+	tests := []struct {
+		in   string
+		want decimal
+	}{
+		{"3,9860", decimal{"3.9860", 39860, 4}},
+		{"0,012188", decimal{"0.012188", 12188, 6}},
+		{"1", decimal{"1", 1, 0}},
+		{" 2,5 ", decimal{"2.5", 25, 1}},
+		{"-0,0766", decimal{"-0.0766", -766, 4}},
+		{"0", decimal{"0", 0, 0}},
+		// Too big for units, only the value is given
+		{"99999999999999999999,1", decimal{"99999999999999999999.1", 0, 0}},
+		{"x", decimal{"x", 0, 0}},
+	}
+	for _, tt := range tests {
+		m := decodeMessage(t, encodeDecimal(tt.in))
+		got := decimal{m.string(df["value"]), m.int64(df["units"]), m.int64(df["scale"])}
+		if got != tt.want {
+			t.Errorf("encodeDecimal(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1", 1},
+		{"100", 100},
+		{" 10000 ", 10000},
+		{"", 1},
+		{"0", 1},
+		{"1,5", 1},
+		{"x", 1},
+	}
+	for _, tt := range tests {
+		if got := ratio(tt.in); got != tt.want {
+			t.Errorf("ratio(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
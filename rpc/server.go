@@ -0,0 +1,258 @@
+// Package rpc serves the currency tables over gRPC, as described by the Rates service in nbp.proto.
+//
+// The service is served with HTTP/2 over plain TCP (h2c) by net/http, the messages are encoded by hand,
+// so no generated code or gRPC library is needed. Compression of the messages is not supported.
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// gRPC status codes used by the service.
+const (
+	codeOK              = 0
+	codeInvalidArgument = 3
+	codeNotFound        = 5
+	codeUnimplemented   = 12
+	codeInternal        = 13
+	codeUnavailable     = 14
+)
+
+// maxMessageSize is the size limit of the request message.
+const maxMessageSize = 1 << 20
+
+// status is the error returned to the client with grpc-status and grpc-message trailers.
+type status struct {
+	code int
+	msg  string
+}
+
+func (s status) Error() string { return s.msg }
+
+// method is an RPC of the service. It calls send for every response message.
+type method struct {
+	fields fields
+	call   func(s *Server, req tableRequest, send func([]byte) error) error
+}
+
+var methods = map[string]method{
+	"/nbp.v1.Rates/GetTable":       {tableRequestFields, (*Server).getTable},
+	"/nbp.v1.Rates/GetLatestTable": {latestRequestFields, (*Server).getLatestTable},
+	"/nbp.v1.Rates/ListTables":     {rangeRequestFields, (*Server).listTables},
+}
+
+// Server serves the Rates service using the backend, the same way the HTTP handlers do.
+type Server struct {
+	Backend svc.Backend
+}
+
+// ListenAndServe serves the Rates service on the address, with HTTP/2 without TLS.
+// HTTP/1 requests are answered with an error.
+func ListenAndServe(addr string, b svc.Backend) error {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Addr: addr, Handler: &Server{Backend: b}, Protocols: &p}
+	return srv.ListenAndServe()
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && ct != "application/grpc+proto" {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Grpc-Accept-Encoding", "identity")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	st := status{code: codeOK}
+	switch err := s.serve(w, r).(type) {
+	case nil:
+	case status:
+		st = err
+	default:
+		if r.Context().Err() != nil {
+			// The client went away
+			return
+		}
+		log.Println(err)
+		st = status{codeInternal, "oops"}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(st.code))
+	if st.msg != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(st.msg))
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	m, ok := methods[r.URL.Path]
+	if !ok {
+		return status{codeUnimplemented, "Unknown method " + r.URL.Path}
+	}
+
+	msg, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	var req tableRequest
+	if err := req.decode(msg, m.fields); err != nil {
+		return status{codeInvalidArgument, "Couldn't decode the request: " + err.Error()}
+	}
+
+	return m.call(s, req, func(b []byte) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		return writeMessage(w, b)
+	})
+}
+
+func (s *Server) getTable(req tableRequest, send func([]byte) error) error {
+	rType, err := req.tableType()
+	if err != nil {
+		return status{codeInvalidArgument, err.Error()}
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		return err
+	}
+	return s.sendLastTable(date, rType, req.codes(), false, send)
+}
+
+func (s *Server) getLatestTable(req tableRequest, send func([]byte) error) error {
+	rType, err := req.tableType()
+	if err != nil {
+		return status{codeInvalidArgument, err.Error()}
+	}
+	return s.sendLastTable(calendar.Today(), rType, req.codes(), true, send)
+}
+
+// sendLastTable sends the table published on the date or the last one before it,
+// with the notice why the table from another date is sent. Unless the latest table is asked for,
+// it fails when the date is today and NBP hasn't published the table yet.
+func (s *Server) sendLastTable(date time.Time, rType string, codes string, latest bool, send func([]byte) error) error {
+	q, err := svc.LastTable(s.Backend, date, rType, codes)
+	if err == svc.ErrNotPublished {
+		return status{codeNotFound, "Resource for given date was not found"}
+	}
+	if err != nil {
+		log.Println(err)
+		return status{codeUnavailable, "There was some problem with your request"}
+	}
+	if !latest && svc.NotYetPublished(date, q) {
+		return status{codeNotFound, svc.NotYetPublishedError{Date: date, Type: rType, Latest: q}.Error()}
+	}
+	return send(encodeTable(q, rType, svc.Notice(date, q)))
+}
+
+// listTables sends the tables published in the period. They are fetched a month at a time,
+// so the first ones are sent before the whole period is read.
+func (s *Server) listTables(req tableRequest, send func([]byte) error) error {
+	rType, err := req.tableType()
+	if err != nil {
+		return status{codeInvalidArgument, err.Error()}
+	}
+	from, err := parseDate(req.From)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(req.To)
+	if err != nil {
+		return err
+	}
+	if err := svc.CheckPeriod(from, to); err != nil {
+		return status{codeInvalidArgument, err.Error()}
+	}
+
+	err = svc.EachTable(s.Backend, from, to, rType, req.codes(), func(q svc.Query) error {
+		if err := send(encodeTable(q, rType, "")); err != nil {
+			return sendError{err}
+		}
+		return nil
+	})
+	if _, ok := err.(sendError); ok || err == nil {
+		return err
+	}
+	log.Println(err)
+	return status{codeUnavailable, "There was some problem with your request"}
+}
+
+// sendError is the error of sending the message, as opposed to the error of the backend.
+type sendError struct{ error }
+
+// parseDate parses the date given in the request, see svc.ParseDate.
+func parseDate(s string) (time.Time, error) {
+	date, err := svc.ParseDate(s, svc.MinDate)
+	if err != nil {
+		return date, status{codeInvalidArgument, err.Error()}
+	}
+	return date, nil
+}
+
+// readMessage reads the single request message, prefixed with the compression flag and the length.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, status{codeInvalidArgument, "Couldn't read the request"}
+	}
+	if prefix[0] != 0 {
+		return nil, status{codeUnimplemented, "Compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxMessageSize {
+		return nil, status{codeInvalidArgument, "The request is too large"}
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, status{codeInvalidArgument, "Couldn't read the request"}
+	}
+	return msg, nil
+}
+
+// writeMessage writes the response message and sends it to the client at once.
+func writeMessage(w http.ResponseWriter, msg []byte) error {
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	if _, err := w.Write(append(buf, msg...)); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// encodeMessage percent-encodes grpc-message trailer, as the gRPC over HTTP/2 spec requires.
+func encodeMessage(msg string) string {
+	var res []byte
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			res = append(res, fmt.Sprintf("%%%02X", c)...)
+			continue
+		}
+		res = append(res, c)
+	}
+	return string(res)
+}
@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"errors"
+)
+
+// Protocol Buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// encoder writes the fields of a message in Protocol Buffers wire format.
+// Fields with default values are omitted, as proto3 does.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) tag(field int, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (e *encoder) int64(field int, v int64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		e.varint(uint64(v))
+	}
+}
+
+func (e *encoder) string(field int, s string) {
+	if s != "" {
+		e.tag(field, wireBytes)
+		e.varint(uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	}
+}
+
+func (e *encoder) message(field int, m []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(m)))
+	e.buf = append(e.buf, m...)
+}
+
+// decoder reads the fields of a message in Protocol Buffers wire format.
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(d.buf) == 0 {
+			return 0, errTruncated
+		}
+		b := d.buf[0]
+		d.buf = d.buf[1:]
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("invalid varint")
+}
+
+// next returns the number and wire type of the next field, or false at the end of the message.
+func (d *decoder) next() (int, int, bool, error) {
+	if len(d.buf) == 0 {
+		return 0, 0, false, nil
+	}
+	t, err := d.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return int(t >> 3), int(t & 7), true, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)) < n {
+		return nil, errTruncated
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// skip skips the value of the field with given wire type, used for unknown fields.
+func (d *decoder) skip(wire int) error {
+	var n int
+	switch wire {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return errors.New("unsupported wire type")
+	}
+	if len(d.buf) < n {
+		return errTruncated
+	}
+	d.buf = d.buf[n:]
+	return nil
+}
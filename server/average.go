@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// currencyAverage is the arithmetic mean of the average rates of the currency in the period.
+// Tables is the number of tables quoting the currency, which is less than all of them if it was added or removed in the period.
+type currencyAverage struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Ratio   string `json:"ratio"`
+	Average string `json:"average"`
+	Tables  int    `json:"tables"`
+}
+
+// periodAverage is the mean of the rates from the tables A published in the period.
+type periodAverage struct {
+	Period     string            `json:"period"`
+	FromDate   string            `json:"fromDate"`
+	ToDate     string            `json:"toDate"`
+	Tables     int               `json:"tables"`
+	FirstTable string            `json:"firstTable"`
+	LastTable  string            `json:"lastTable"`
+	Currencies []currencyAverage `json:"currencies"`
+}
+
+// MonthlyAverageHandler returns the mean of the average rates from the tables A published in the month given as YYYY-MM.
+func MonthlyAverageHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	month, err := time.Parse("2006-01", p.ByName("month"))
+	if err != nil {
+		return badRequest{errors.New("Given month is wrong. Use 'YYYY-MM'")}
+	}
+	if month.After(calendar.Today()) {
+		return badRequest{errors.New("Given month is wrong. Can't use future month")}
+	}
+	if month.AddDate(0, 1, -1).Before(svc.MinDate) {
+		return badRequest{errors.New("Given month is wrong. Min month is " + svc.MinDate.Format("2006-01"))}
+	}
+
+	return averageOver(w, p.ByName("month"), month, month.AddDate(0, 1, -1), p.ByName("code"))
+}
+
+// YearlyAverageHandler returns the mean of the average rates from the tables A published in the year.
+func YearlyAverageHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	year, err := strconv.Atoi(p.ByName("year"))
+	if err != nil || year < svc.MinDate.Year() || year > calendar.Today().Year() {
+		return badRequest{errors.New("Given year is wrong. Use 'YYYY' from 2002 to the current year")}
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return averageOver(w, p.ByName("year"), from, from.AddDate(1, 0, -1), p.ByName("code"))
+}
+
+// averageOver writes the mean of the rates from the tables A published between from and to.
+// The period of the current month or year ends today.
+// When the ratio of the currency changed in the period, the rates are recomputed for the last ratio.
+func averageOver(w http.ResponseWriter, period string, from time.Time, to time.Time, code string) error {
+	if from.Before(svc.MinDate) {
+		from = svc.MinDate
+	}
+	if today := calendar.Today(); to.After(today) {
+		to = today
+	}
+
+	res := periodAverage{Period: period, Currencies: []currencyAverage{}}
+	var codes []string
+	averages := map[string]*currencyAverage{}
+	sums := map[string]*big.Rat{}
+	// The averages get as many decimal places as the rates they're computed from
+	places := map[string]int{}
+
+	err := svc.EachTable(backend, from, to, "avg", code, func(q svc.Query) error {
+		if res.Tables == 0 {
+			res.FromDate, res.FirstTable = q.FromData, q.TableNumber
+		}
+		res.ToDate, res.LastTable = q.FromData, q.TableNumber
+		res.Tables++
+
+		for _, c := range q.Currencies {
+			rate, err := svc.Rate(c.Average, c.Ratio)
+			if err != nil {
+				continue
+			}
+
+			a, ok := averages[c.Code]
+			if !ok {
+				a = &currencyAverage{Code: c.Code}
+				averages[c.Code] = a
+				sums[c.Code] = new(big.Rat)
+				codes = append(codes, c.Code)
+			}
+			a.Name, a.Ratio = c.Name, c.Ratio
+			a.Tables++
+			sums[c.Code].Add(sums[c.Code], rate)
+			if p := svc.Places(c.Average); p > places[c.Code] {
+				places[c.Code] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+	if res.Tables == 0 {
+		return badRequest{errors.New("Resource for given period was not found")}
+	}
+
+	for _, code := range codes {
+		a := averages[code]
+		ratio, err := svc.ParseDecimal(a.Ratio)
+		if err != nil {
+			continue
+		}
+		avg := sums[code].Quo(sums[code], big.NewRat(int64(a.Tables), 1))
+		a.Average = svc.FormatDecimal(avg.Mul(avg, ratio), places[code])
+		res.Currencies = append(res.Currencies, *a)
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestAverageHandlers(t *testing.T) {
+	defer withStub()()
+
+	month := func(m string) httprouter.Params {
+		return httprouter.Params{{Key: "month", Value: m}, {Key: "code", Value: "USD,EUR"}}
+	}
+	year := func(y string) httprouter.Params {
+		return httprouter.Params{{Key: "year", Value: y}, {Key: "code", Value: "USD,EUR"}}
+	}
+	tests := []struct {
+		name    string
+		handler handle
+		params  httprouter.Params
+		status  int
+		want    string
+	}{
+		{"month", MonthlyAverageHandler, month("2015-11"), http.StatusOK,
+			`"period":"2015-11","fromDate":"2015-11-09","toDate":"2015-11-30","tables":7,"firstTable":"216/A/NBP/2015","lastTable":"231/A/NBP/2015"`},
+		{"month rates", MonthlyAverageHandler, month("2015-11"), http.StatusOK,
+			`"currencies":[{"code":"USD","name":"dolar amerykański","ratio":"1","average":"3,8691","tables":7},{"code":"EUR","name":"euro","ratio":"1","average":"4,2352","tables":7}]`},
+		{"year", YearlyAverageHandler, year("2015"), http.StatusOK, `"period":"2015","fromDate":"2015-11-09","toDate":"2015-11-30","tables":7`},
+		{"no tables", MonthlyAverageHandler, month("2015-10"), http.StatusBadRequest, "Resource for given period was not found"},
+		{"wrong month", MonthlyAverageHandler, month("2015-13"), http.StatusBadRequest, "Given month is wrong. Use 'YYYY-MM'"},
+		{"future month", MonthlyAverageHandler, month("2999-01"), http.StatusBadRequest, "Can't use future month"},
+		{"month too early", MonthlyAverageHandler, month("2001-12"), http.StatusBadRequest, "Min month is 2002-01"},
+		{"wrong year", YearlyAverageHandler, year("2001"), http.StatusBadRequest, "Given year is wrong"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(tt.handler)(w, httptest.NewRequest("GET", "/", nil), tt.params)
+
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: %d %s, want %d with %s", tt.name, w.Code, w.Body, tt.status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// fixtureRates are the rates of USD and EUR in the fixture tables A and C of November 2015.
+// There is no table of 2015-11-26, as if NBP didn't publish on the day the calendar expects one.
+var fixtureRates = []struct {
+	date            string
+	number          int
+	usd, eur        string
+	usdBuy, usdSell string
+	eurBuy, eurSell string
+}{
+	{"2015-11-09", 216, "3,9020", "4,2047", "3,8630", "3,9410", "4,1631", "4,2473"},
+	{"2015-11-10", 217, "3,9226", "4,2198", "3,8836", "3,9620", "4,1780", "4,2624"},
+	{"2015-11-12", 218, "3,8938", "4,1870", "3,8552", "3,9330", "4,1455", "4,2293"},
+	{"2015-11-24", 228, "3,8280", "4,2535", "3,7897", "3,8663", "4,2114", "4,2964"},
+	{"2015-11-25", 229, "3,8290", "4,2537", "3,7907", "3,8673", "4,2116", "4,2966"},
+	{"2015-11-27", 230, "3,8480", "4,2640", "3,8099", "3,8869", "4,2218", "4,3070"},
+	{"2015-11-30", 231, "3,8602", "4,2639", "3,8220", "3,8992", "4,2217", "4,3069"},
+}
+
+// fixtureTables are the tables by type and date, e.g. "avg/2015-11-25".
+var fixtureTables = func() map[string]svc.Query {
+	res := map[string]svc.Query{}
+	for _, r := range fixtureRates {
+		add := func(sType string, letter string, currencies string) {
+			var q svc.Query
+			err := json.Unmarshal([]byte(fmt.Sprintf(`{"fromDate":%q,"tableNumber":"%d/%s/NBP/2015","currencies":[%s]}`,
+				r.date, r.number, letter, currencies)), &q)
+			if err != nil {
+				panic(err)
+			}
+			res[sType+"/"+r.date] = q
+		}
+		add("avg", "A", fmt.Sprintf(`{"code":"USD","name":"dolar amerykański","ratio":"1","average":%q},
+			{"code":"EUR","name":"euro","ratio":"1","average":%q}`, r.usd, r.eur))
+		add("both", "C", fmt.Sprintf(`{"code":"USD","name":"dolar amerykański","ratio":"1","buy":%q,"sell":%q},
+			{"code":"EUR","name":"euro","ratio":"1","buy":%q,"sell":%q}`, r.usdBuy, r.usdSell, r.eurBuy, r.eurSell))
+	}
+	return res
+}()
+
+// stubBackend serves fixtureTables.
+type stubBackend struct{}
+
+func (stubBackend) Table(date time.Time, sType string, code string) (svc.Query, error) {
+	q, ok := fixtureTables[sType+"/"+date.Format("2006-01-02")]
+	if !ok {
+		return svc.Query{}, svc.ErrNotPublished
+	}
+	return svc.FilterCurrencies(q, code), nil
+}
+
+func (b stubBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]svc.Query, error) {
+	res := []svc.Query{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if q, err := b.Table(d, sType, code); err == nil {
+			res = append(res, q)
+		}
+	}
+	return res, nil
+}
+
+// withStub makes the handlers use stubBackend, until the returned function is called.
+func withStub() func() {
+	prev := backend
+	backend = stubBackend{}
+	return func() { backend = prev }
+}
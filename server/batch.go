@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// maxBatchItems is the maximum number of items in one batch request.
+	maxBatchItems = 1000
+	// maxBatchBody is the longest batch request, enough for the items with all codes.
+	maxBatchBody = 1 << 20
+	// batchWorkers is the number of items resolved at the same time.
+	batchWorkers = 8
+)
+
+// batchItem is a single query in the batch request.
+type batchItem struct {
+	Date  string   `json:"date"`
+	Type  string   `json:"type"`
+	Codes []string `json:"codes,omitempty"`
+}
+
+// batchResult is the result of a single query in the batch request.
+// Like the whole response, it follows JSend: there is data on success and a message on error.
+type batchResult struct {
+	batchItem
+	Status  string `json:"status"`
+	Data    *table `json:"data,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// BatchHandler resolves many queries given as a JSON array of {date, type, codes} items.
+// The result or the error of every item is returned in the same order as the items.
+func BatchHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	var items []batchItem
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&items)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return badRequest{errors.New("Given batch is too big. Max size is 1 MiB")}
+	}
+	if err != nil {
+		return badRequest{errors.New("Given batch is wrong. Send JSON array of {date, type, codes} items")}
+	}
+	if len(items) > maxBatchItems {
+		return badRequest{errors.New("Given batch is too big. Max number of items is 1000")}
+	}
+	// Every item costs as much as a request, the first one is paid already
+	if err := charge(r, len(items)-1); err != nil {
+		return err
+	}
+
+	res := make([]batchResult, len(items))
+	idx := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < batchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				res[i] = resolveBatchItem(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
+
+// resolveBatchItem fetches the rates for the item the same way as IndexHandler does.
+func resolveBatchItem(item batchItem) batchResult {
+	code := "*"
+	if len(item.Codes) > 0 {
+		code = strings.Join(item.Codes, ",")
+	}
+
+	res := batchResult{batchItem: item}
+	q, err := rates(item.Date, item.Type, code)
+	switch err.(type) {
+	case nil:
+		res.Status = "success"
+		res.Data = &q
+	case badRequest, notPublished:
+		res.Status = "error"
+		res.Message = err.Error()
+	default:
+		res.Status = "error"
+		res.Message = "oops"
+	}
+	return res
+}
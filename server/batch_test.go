@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchHandler(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   []string
+	}{
+		{"items", `[{"date": "2015-11-25", "type": "avg", "codes": ["USD"]}, {"date": "2015-11-28", "type": "both", "codes": ["EUR"]}]`,
+			http.StatusOK, []string{
+				`"date":"2015-11-25","type":"avg","codes":["USD"],"status":"success"`,
+				`"tableNumber":"229/A/NBP/2015","currencies":[{"code":"USD"`,
+				`"tableNumber":"230/C/NBP/2015","currencies":[{"code":"EUR"`,
+				`"notice":"No table published on 2015-11-28: weekend"`,
+			}},
+		{"failed item", `[{"date": "2015-11-25", "type": "avg"}, {"date": "x", "type": "avg"}, {"date": "2015-11-25", "type": "x"}]`,
+			http.StatusOK, []string{
+				`"date":"2015-11-25","type":"avg","status":"success"`,
+				`"date":"x","type":"avg","status":"error","message":"Given date is wrong`,
+				`"type":"x","status":"error","message":"Given type is wrong`,
+			}},
+		{"empty", `[]`, http.StatusOK, []string{`"data":[]`}},
+		{"malformed", `{"date": "2015-11-25"}`, http.StatusBadRequest, []string{"Given batch is wrong"}},
+		{"too many items", "[" + strings.Repeat(`{},`, maxBatchItems) + "{}]", http.StatusBadRequest, []string{"Max number of items is 1000"}},
+		{"too big", `[{"date": "2015-11-25", "type": "avg", "codes": [` + strings.Repeat(`"USD",`, maxBatchBody/6) + `"EUR"]}]`,
+			http.StatusBadRequest, []string{"Max size is 1 MiB"}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(BatchHandler)(w, httptest.NewRequest("POST", "/v1/batch", strings.NewReader(tt.body)), nil)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.status)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s: %s, want %s", tt.name, w.Body, want)
+			}
+		}
+	}
+}
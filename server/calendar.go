@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// holiday is a public holiday on which no table is published.
+type holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// mismatch is a date on which the calendar doesn't agree with the dir index.
+type mismatch struct {
+	Date      string `json:"date"`
+	Published bool   `json:"published"`
+}
+
+// publicationCalendar lists the days without tables in the year, cross-checked against the dir index.
+type publicationCalendar struct {
+	Year       int        `json:"year"`
+	Holidays   []holiday  `json:"holidays"`
+	Mismatches []mismatch `json:"mismatches"`
+}
+
+// CalendarHandler returns the public holidays of the given year
+// and the dates on which the tables listed in the dir index don't match the calendar.
+func CalendarHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	year, err := strconv.Atoi(p.ByName("year"))
+	if err != nil || year < svc.MinDate.Year() || year > calendar.Today().Year() {
+		return badRequest{errors.New("Given year is wrong. Use 'YYYY' from 2002 to the current year")}
+	}
+
+	res := publicationCalendar{Year: year, Holidays: []holiday{}, Mismatches: []mismatch{}}
+	for _, h := range calendar.Holidays(year) {
+		res.Holidays = append(res.Holidays, holiday{Date: h.Date.Format("2006-01-02"), Name: h.Name})
+	}
+
+	entries, err := svc.GetIndex(year)
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+
+	var published []time.Time
+	for _, e := range entries {
+		if e.Type == "a" {
+			published = append(published, e.Date)
+		}
+	}
+
+	// Check the whole year, but the current one only until today
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if today := calendar.Today(); to.After(today) {
+		to = calendar.PreviousPublicationDay(today)
+	}
+	if year == svc.MinDate.Year() {
+		from = svc.MinDate
+	}
+
+	for _, m := range calendar.Check(published, from, to) {
+		res.Mismatches = append(res.Mismatches, mismatch{Date: m.Date.Format("2006-01-02"), Published: m.Published})
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// encodings are the content codings the responses can be compressed with, by name used in Accept-Encoding header.
+var encodings = map[string]func(w io.Writer) io.WriteCloser{
+	"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+}
+
+// encodingPreference is the order the codings are chosen in, when the client accepts them equally.
+var encodingPreference = []string{"br", "zstd", "gzip"}
+
+var encodingsMu sync.RWMutex
+
+// RegisterEncoding adds the content coding the responses can be compressed with, e.g. "br" for brotli.
+// The writer returned by newWriter has to write everything on Close, and should implement Flush() error
+// so the streamed responses are sent as they're written.
+func RegisterEncoding(name string, newWriter func(w io.Writer) io.WriteCloser) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[name] = newWriter
+	if !contains(encodingPreference, name) {
+		encodingPreference = append(encodingPreference, name)
+	}
+}
+
+// compressHandler compresses the responses with the coding negotiated with Accept-Encoding header.
+type compressHandler struct {
+	next http.Handler
+}
+
+func (h compressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	name, newWriter := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if newWriter == nil || r.Method == "HEAD" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressWriter{ResponseWriter: w, name: name, newWriter: newWriter}
+	defer cw.close()
+	h.next.ServeHTTP(cw, r)
+}
+
+// negotiateEncoding returns the supported coding with the highest quality in Accept-Encoding header,
+// or nil when the response shouldn't be compressed.
+func negotiateEncoding(accept string) (string, func(w io.Writer) io.WriteCloser) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+
+	quality := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if name != "" {
+			quality[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range encodingPreference {
+		q, ok := quality[name]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if _, supported := encodings[name]; ok && supported && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return best, encodings[best]
+}
+
+// compressWriter compresses the body of the response, unless it's an event stream or it's already encoded.
+// The decision is made when the header is written.
+type compressWriter struct {
+	http.ResponseWriter
+	name      string
+	newWriter func(w io.Writer) io.WriteCloser
+	enc       io.WriteCloser
+	decided   bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	hdr := w.Header()
+	stream := strings.HasPrefix(hdr.Get("Content-Type"), "text/event-stream")
+	if code != http.StatusNoContent && code != http.StatusNotModified && hdr.Get("Content-Encoding") == "" && !stream {
+		hdr.Set("Content-Encoding", w.name)
+		hdr.Del("Content-Length")
+		w.enc = w.newWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+// Flush implements http.Flusher, so the streamed responses still work.
+func (w *compressWriter) Flush() {
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// nopCloser is an encoder for the tests which doesn't change the body.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0", ""},
+		{"gzip; q=0.5", "gzip"},
+		{"*", "gzip"},
+		{"*;q=0, gzip", "gzip"},
+		{"*, gzip;q=0", ""},
+		{"deflate", ""},
+		{"identity", ""},
+		{"br", ""},
+		{"gzip;q=x", "gzip"},
+	}
+	for _, tt := range tests {
+		got, w := negotiateEncoding(tt.accept)
+		if got != tt.want || (w == nil) != (tt.want == "") {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateRegisteredEncoding(t *testing.T) {
+	preference := encodingPreference
+	defer func() {
+		delete(encodings, "x-test")
+		encodingPreference = preference
+	}()
+	RegisterEncoding("x-test", func(w io.Writer) io.WriteCloser { return nopCloser{w} })
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"x-test", "x-test"},
+		// The codings accepted equally are chosen in the order of preference, the registered ones are last
+		{"x-test, gzip", "gzip"},
+		{"x-test, gzip;q=0.5", "x-test"},
+		{"*", "gzip"},
+	}
+	for _, tt := range tests {
+		if got, _ := negotiateEncoding(tt.accept); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompressHandler(t *testing.T) {
+	const body = `{"data":"ok","status":"success"}`
+	tests := []struct {
+		name        string
+		method      string
+		accept      string
+		contentType string
+		code        int
+		compressed  bool
+	}{
+		{"gzip", "GET", "gzip", "application/json", http.StatusOK, true},
+		{"error", "GET", "gzip", "application/json", http.StatusNotFound, true},
+		{"not accepted", "GET", "", "application/json", http.StatusOK, false},
+		{"head", "HEAD", "gzip", "application/json", http.StatusOK, false},
+		{"event stream", "GET", "gzip", "text/event-stream", http.StatusOK, false},
+		{"no content", "GET", "gzip", "", http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		h := compressHandler{next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.WriteHeader(tt.code)
+			if tt.code != http.StatusNoContent {
+				io.WriteString(w, body)
+			}
+		})}
+		r := httptest.NewRequest(tt.method, "/v1/rates/2015-11-25/avg/USD", nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q, want Accept-Encoding", tt.name, got)
+		}
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if got := w.Header().Get("Content-Encoding") == "gzip"; got != tt.compressed {
+			t.Errorf("%s: compressed = %v, want %v", tt.name, got, tt.compressed)
+			continue
+		}
+		if !tt.compressed {
+			continue
+		}
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, err := ioutil.ReadAll(zr); err != nil || string(b) != body {
+			t.Errorf("%s: body = %q, %v, want %q", tt.name, b, err, body)
+		}
+	}
+}
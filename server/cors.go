@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS is the policy of the cross-origin requests made by the browsers.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the API, e.g. "https://example.com".
+	// "*" allows any origin and "https://*.example.com" any subdomain. No origin is allowed when it's empty.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in the cross-origin requests, GET, POST, PUT and DELETE when empty.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in the cross-origin requests,
+	// Content-Type, Authorization, X-API-Key and Last-Event-ID when empty.
+	AllowedHeaders []string
+	// AllowCredentials allows the requests with cookies and Authorization header set by the browser.
+	// The origins have to be given then, any page reading the responses of the logged in users is never allowed.
+	AllowCredentials bool
+	// MaxAge is how long the browsers can cache the preflight response.
+	MaxAge time.Duration
+}
+
+// Validate reports the policy which can't be applied.
+func (c CORS) Validate() error {
+	if c.AllowCredentials && contains(c.AllowedOrigins, "*") {
+		return errors.New("Origin '*' can't be allowed with the credentials. Give the origins explicitly")
+	}
+	return nil
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", apiKeyHeader, "Last-Event-ID"}
+	// corsExposedHeaders are the response headers the scripts can read, besides the simple ones.
+	corsExposedHeaders = []string{"Retry-After", "Deprecation", "Sunset", "Link"}
+)
+
+// corsHandler applies the CORS policy to the requests and answers the preflight requests
+// without passing them to the routes.
+type corsHandler struct {
+	CORS
+	next http.Handler
+}
+
+func (h corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Add("Vary", "Origin")
+	allowed := h.allowOrigin(origin)
+	if allowed != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		// Any origin doesn't get the credentials, even if the policy wasn't validated
+		if h.AllowCredentials && allowed != "*" {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if r.Method != "OPTIONS" || r.Header.Get("Access-Control-Request-Method") == "" {
+		if allowed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Preflight request. Without the allow headers the browser won't send the actual request.
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if allowed != "" && contains(h.methods(), method) && h.allowHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(h.methods(), ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(h.headers(), ", "))
+		if h.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(h.MaxAge.Seconds())))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin header for the origin, or "" when it's not allowed.
+func (h corsHandler) allowOrigin(origin string) string {
+	for _, o := range h.AllowedOrigins {
+		switch {
+		case o == "*":
+			return "*"
+		case strings.EqualFold(o, origin):
+			return origin
+		case strings.Contains(o, "://*."):
+			// Any subdomain, e.g. https://app.example.com for https://*.example.com
+			i := strings.Index(o, "*.")
+			lower := strings.ToLower(origin)
+			if strings.HasPrefix(lower, strings.ToLower(o[:i])) && strings.HasSuffix(lower, strings.ToLower(o[i+1:])) {
+				return origin
+			}
+		}
+	}
+	return ""
+}
+
+// allowHeaders reports whether all the headers requested by the preflight request are allowed.
+func (h corsHandler) allowHeaders(requested string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		if name = strings.TrimSpace(name); name != "" && !contains(h.headers(), name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h corsHandler) methods() []string {
+	if len(h.AllowedMethods) == 0 {
+		return defaultCORSMethods
+	}
+	return h.AllowedMethods
+}
+
+func (h corsHandler) headers() []string {
+	if len(h.AllowedHeaders) == 0 {
+		return defaultCORSHeaders
+	}
+	return h.AllowedHeaders
+}
+
+// contains reports whether the list contains the value, ignoring the case.
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		creds   bool
+		origin  string
+		want    string
+	}{
+		{"none", nil, false, "https://example.com", ""},
+		{"any", []string{"*"}, false, "https://example.com", "*"},
+		{"exact", []string{"https://example.com"}, false, "https://example.com", "https://example.com"},
+		{"exact case", []string{"https://Example.com"}, false, "https://example.com", "https://example.com"},
+		{"other scheme", []string{"https://example.com"}, false, "http://example.com", ""},
+		{"other port", []string{"https://example.com"}, false, "https://example.com:8080", ""},
+		{"second", []string{"https://a.com", "https://b.com"}, false, "https://b.com", "https://b.com"},
+		{"subdomain", []string{"https://*.example.com"}, false, "https://app.example.com", "https://app.example.com"},
+		{"nested subdomain", []string{"https://*.example.com"}, false, "https://a.b.example.com", "https://a.b.example.com"},
+		{"subdomain case", []string{"https://*.example.com"}, false, "https://APP.Example.com", "https://APP.Example.com"},
+		{"subdomain of apex", []string{"https://*.example.com"}, false, "https://example.com", ""},
+		{"subdomain suffix", []string{"https://*.example.com"}, false, "https://app.example.com.evil.com", ""},
+		{"subdomain lookalike", []string{"https://*.example.com"}, false, "https://evilexample.com", ""},
+		{"subdomain scheme", []string{"https://*.example.com"}, false, "http://app.example.com", ""},
+		{"exact with credentials", []string{"https://example.com"}, true, "https://example.com", "https://example.com"},
+		{"any with credentials", []string{"*"}, true, "https://evil.com", "*"},
+	}
+	for _, tt := range tests {
+		h := corsHandler{CORS: CORS{AllowedOrigins: tt.allowed, AllowCredentials: tt.creds}}
+		if got := h.allowOrigin(tt.origin); got != tt.want {
+			t.Errorf("%s: allowOrigin(%q) = %q, want %q", tt.name, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("preflight request %s passed to the routes", r.Header.Get("Access-Control-Request-Method"))
+	})
+	h := corsHandler{CORS: CORS{AllowedOrigins: []string{"https://example.com"}}, next: next}
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"get", "https://example.com", "GET", "", true},
+		{"post with headers", "https://example.com", "POST", "content-type, x-api-key", true},
+		{"method", "https://example.com", "PATCH", "", false},
+		{"header", "https://example.com", "GET", "X-Custom", false},
+		{"origin", "https://evil.com", "GET", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("OPTIONS", "/v1/batch", nil)
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set("Access-Control-Request-Method", tt.method)
+		r.Header.Set("Access-Control-Request-Headers", tt.headers)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, http.StatusNoContent)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods") != ""; got != tt.allowed {
+			t.Errorf("%s: allowed = %v, want %v", tt.name, got, tt.allowed)
+		}
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	h := corsHandler{CORS: CORS{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}, next: next}
+
+	r := httptest.NewRequest("GET", "/v1/rates/2015-11-25/avg/USD", nil)
+	r.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if !called {
+		t.Error("request not passed to the routes")
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Expose-Headers":    "Retry-After, Deprecation, Sunset, Link",
+		"Vary":                             "Origin",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCORSAnyOriginWithoutCredentials(t *testing.T) {
+	h := corsHandler{CORS: CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}, next: http.NotFoundHandler()}
+	r := httptest.NewRequest("GET", "/v1/webhooks", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q for any origin", got)
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	tests := []struct {
+		c  CORS
+		ok bool
+	}{
+		{CORS{AllowedOrigins: []string{"*"}}, true},
+		{CORS{AllowedOrigins: []string{"https://example.com", "https://*.example.com"}, AllowCredentials: true}, true},
+		{CORS{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true}, false},
+		{CORS{AllowCredentials: true}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: Validate = %v, want ok %v", tt.c, err, tt.ok)
+		}
+	}
+}
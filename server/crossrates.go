@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// crossPlaces is the number of decimal places of the cross rates.
+const crossPlaces = 6
+
+// crossRates is the matrix of the rates between the currencies from table A.
+// Rates[from][to] is the amount of "to" currency for a single unit of "from" currency.
+type crossRates struct {
+	Date        string                       `json:"date"`
+	FromData    string                       `json:"fromDate"`
+	TableNumber string                       `json:"tableNumber"`
+	Codes       []string                     `json:"codes"`
+	Rates       map[string]map[string]string `json:"rates"`
+	Notice      string                       `json:"notice,omitempty"`
+}
+
+// baseRates returns table A for the date with the rates expressed in the base currency instead of PLN, filtered by code.
+func baseRates(rDate string, rType string, rCode string, base string) (table, error) {
+	if rType != "avg" {
+		return table{}, badRequest{errors.New("Given type is wrong. Base currency can be used only with 'avg'")}
+	}
+
+	t, err := rates(rDate, rType, "*")
+	if err != nil {
+		return table{}, err
+	}
+
+	q, err := svc.Rebase(t.Query, base, crossPlaces)
+	if err == svc.ErrNoCurrency {
+		return table{}, badRequest{errors.New("Given base is wrong. Currency " + base + " not found in table " + t.TableNumber)}
+	}
+	if err != nil {
+		return table{}, err
+	}
+
+	t.Query = svc.FilterCurrencies(q, rCode)
+	t.Base = base
+	return t, nil
+}
+
+// CrossRatesHandler returns the matrix of the rates between every two of given currencies,
+// computed from the average rates in table A published on the date or the last one before it.
+func CrossRatesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	t, err := rates(p.ByName("date"), "avg", "*")
+	if err != nil {
+		return err
+	}
+
+	var codes []string
+	if code := p.ByName("code"); code == "*" {
+		for _, c := range t.Currencies {
+			codes = append(codes, c.Code)
+		}
+		codes = append(codes, "PLN")
+	} else {
+		codes = strings.Split(strings.ToUpper(code), ",")
+	}
+
+	// The small rates, like of HUF, get more decimal places
+	extra := map[string]int{}
+	for _, c := range t.Currencies {
+		extra[c.Code] = svc.ExtraPlaces(c.Average)
+	}
+
+	// The rate of every currency in PLN, checked before the matrix is computed
+	plnRates := map[string]*big.Rat{}
+	for _, code := range codes {
+		rate, err := svc.PLNRate(t.Query, code)
+		if err == svc.ErrNoCurrency {
+			return badRequest{errors.New("Given code is wrong. Currency " + code + " not found in table " + t.TableNumber)}
+		}
+		if err != nil {
+			return err
+		}
+		if rate.Sign() == 0 {
+			return badRequest{errors.New("Given code is wrong. Couldn't use the rate of " + code)}
+		}
+		plnRates[code] = rate
+	}
+
+	res := crossRates{Date: t.Date, FromData: t.FromData, TableNumber: t.TableNumber, Codes: codes, Rates: map[string]map[string]string{}, Notice: t.Notice}
+	for _, from := range codes {
+		res.Rates[from] = map[string]string{}
+		for _, to := range codes {
+			rate := new(big.Rat).Quo(plnRates[from], plnRates[to])
+			res.Rates[from][to] = svc.FormatDecimal(rate, crossPlaces+extra[from])
+		}
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
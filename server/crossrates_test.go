@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestCrossRatesHandler(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		name   string
+		date   string
+		code   string
+		status int
+		codes  []string
+		// rates are some of the rates expected in the matrix
+		rates  map[string]map[string]string
+		notice string
+	}{
+		{"codes", "2015-11-25", "USD,EUR", http.StatusOK, []string{"USD", "EUR"}, map[string]map[string]string{
+			"USD": {"USD": "1,000000", "EUR": "0,900158"},
+			"EUR": {"USD": "1,110917", "EUR": "1,000000"},
+		}, ""},
+		{"pln", "2015-11-25", "usd,pln", http.StatusOK, []string{"USD", "PLN"}, map[string]map[string]string{
+			"USD": {"PLN": "3,829000"},
+			"PLN": {"USD": "0,261165"},
+		}, ""},
+		{"all", "2015-11-25", "*", http.StatusOK, []string{"USD", "EUR", "PLN"}, map[string]map[string]string{
+			"PLN": {"EUR": "0,235089"},
+		}, ""},
+		{"weekend", "2015-11-28", "USD,EUR", http.StatusOK, []string{"USD", "EUR"}, map[string]map[string]string{
+			"EUR": {"USD": "1,108108"},
+		}, "No table published on 2015-11-28: weekend"},
+		{"unknown code", "2015-11-25", "USD,XYZ", http.StatusBadRequest, nil, nil, ""},
+		{"wrong date", "2015-11-31", "USD,EUR", http.StatusBadRequest, nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(CrossRatesHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{{Key: "date", Value: tt.date}, {Key: "code", Value: tt.code}})
+
+		if w.Code != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var res struct {
+			Data crossRates
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(res.Data.Codes, tt.codes) || res.Data.Notice != tt.notice {
+			t.Errorf("%s: codes %v with notice %q, want %v with %q", tt.name, res.Data.Codes, res.Data.Notice, tt.codes, tt.notice)
+		}
+		for from, to := range tt.rates {
+			for code, want := range to {
+				if got := res.Data.Rates[from][code]; got != want {
+					t.Errorf("%s: %s to %s = %s, want %s", tt.name, from, code, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestBaseCurrency(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		name   string
+		url    string
+		rType  string
+		status int
+		want   string
+	}{
+		{"base", "/?base=usd", "avg", http.StatusOK, `"currencies":[{"code":"EUR","name":"euro","ratio":"1","average":"1,110917"`},
+		{"pln added", "/?base=USD", "avg", http.StatusOK, `{"code":"PLN","name":"złoty polski","ratio":"1","average":"0,261165"`},
+		{"base given", "/?base=USD", "avg", http.StatusOK, `"base":"USD"`},
+		{"table C", "/?base=USD", "both", http.StatusBadRequest, "Base currency can be used only with 'avg'"},
+		{"unknown base", "/?base=XYZ", "avg", http.StatusBadRequest, "Currency XYZ not found in table 229/A/NBP/2015"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(IndexHandler)(w, httptest.NewRequest("GET", tt.url, nil), httprouter.Params{
+			{Key: "date", Value: "2015-11-25"}, {Key: "type", Value: tt.rType}, {Key: "code", Value: "EUR,PLN"}})
+
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: %d %s, want %d with %s", tt.name, w.Code, w.Body, tt.status, tt.want)
+		}
+	}
+}
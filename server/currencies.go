@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// currencyName is a currency quoted in the table.
+type currencyName struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// currencies lists the currencies quoted in the table.
+type currencies struct {
+	Date        string         `json:"date"`
+	FromData    string         `json:"fromDate"`
+	TableNumber string         `json:"tableNumber"`
+	Currencies  []currencyName `json:"currencies"`
+	Notice      string         `json:"notice,omitempty"`
+}
+
+// CurrenciesHandler returns the codes and names of the currencies in the table of given type
+// published on the date or the last one before it.
+func CurrenciesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	t, err := rates(p.ByName("date"), p.ByName("type"), "*")
+	if err != nil {
+		return err
+	}
+
+	res := currencies{Date: t.Date, FromData: t.FromData, TableNumber: t.TableNumber, Currencies: []currencyName{}, Notice: t.Notice}
+	for _, c := range t.Currencies {
+		res.Currencies = append(res.Currencies, currencyName{Code: c.Code, Name: c.Name})
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
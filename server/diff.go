@@ -0,0 +1,115 @@
+package server
+
+import (
+	"math/big"
+	"net/http"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// tableRef identifies the table used for the requested date.
+type tableRef struct {
+	Date        string `json:"date"`
+	FromData    string `json:"fromDate"`
+	TableNumber string `json:"tableNumber"`
+	Notice      string `json:"notice,omitempty"`
+}
+
+// rateChange is the change of the rate between two tables.
+type rateChange struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Change  string `json:"change"`
+	Percent string `json:"percent"`
+}
+
+// currencyDiff is the change of the rates of the currency, average for table A, buy and sell for table C.
+type currencyDiff struct {
+	Code    string      `json:"code"`
+	Name    string      `json:"name"`
+	Ratio   string      `json:"ratio"`
+	Average *rateChange `json:"average,omitempty"`
+	Buy     *rateChange `json:"buy,omitempty"`
+	Sell    *rateChange `json:"sell,omitempty"`
+}
+
+// rateDiff compares the rates of the currencies from the tables used for two dates.
+type rateDiff struct {
+	From       tableRef       `json:"from"`
+	To         tableRef       `json:"to"`
+	Currencies []currencyDiff `json:"currencies"`
+}
+
+// DiffHandler compares the rates from the tables published on two dates, or the last ones before them,
+// e.g. to explain the exchange gain or loss between the invoice and the payment.
+// Only the currencies quoted in both tables are compared.
+func DiffHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	rType, rCode := p.ByName("type"), p.ByName("code")
+	from, err := rates(p.ByName("date1"), rType, rCode)
+	if err != nil {
+		return err
+	}
+	to, err := rates(p.ByName("date2"), rType, rCode)
+	if err != nil {
+		return err
+	}
+
+	res := rateDiff{
+		From:       tableRef{Date: from.Date, FromData: from.FromData, TableNumber: from.TableNumber, Notice: from.Notice},
+		To:         tableRef{Date: to.Date, FromData: to.FromData, TableNumber: to.TableNumber, Notice: to.Notice},
+		Currencies: []currencyDiff{},
+	}
+
+	for _, t := range to.Currencies {
+		for _, f := range from.Currencies {
+			if f.Code != t.Code {
+				continue
+			}
+
+			d := currencyDiff{Code: t.Code, Name: t.Name, Ratio: t.Ratio}
+			if rType == "avg" {
+				d.Average = compareRates(f.Average, f.Ratio, t.Average, t.Ratio)
+			} else {
+				d.Buy = compareRates(f.Buy, f.Ratio, t.Buy, t.Ratio)
+				d.Sell = compareRates(f.Sell, f.Ratio, t.Sell, t.Ratio)
+			}
+			res.Currencies = append(res.Currencies, d)
+		}
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
+
+// compareRates returns the change of the rate, or nil when the rates can't be compared.
+// When the ratio changed between the tables, the first rate is recomputed for the new ratio.
+func compareRates(from string, fromRatio string, to string, toRatio string) *rateChange {
+	f, err := svc.Rate(from, fromRatio)
+	if err != nil {
+		return nil
+	}
+	ratio, err := svc.ParseDecimal(toRatio)
+	if err != nil {
+		return nil
+	}
+	f.Mul(f, ratio)
+	if f.Sign() == 0 {
+		return nil
+	}
+	t, err := svc.ParseDecimal(to)
+	if err != nil {
+		return nil
+	}
+
+	change := new(big.Rat).Sub(t, f)
+	percent := new(big.Rat).Quo(change, f)
+	percent.Mul(percent, big.NewRat(100, 1))
+	return &rateChange{
+		From:    from,
+		To:      to,
+		Change:  svc.FormatDecimal(change, svc.Places(to)),
+		Percent: svc.FormatDecimal(percent, svc.PercentPlaces),
+	}
+}
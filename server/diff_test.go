@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestDiffHandler(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		name         string
+		date1, date2 string
+		rType, code  string
+		status       int
+		want         []currencyDiff
+		// notice is the notice of the second table
+		notice string
+	}{
+		{"average", "2015-11-24", "2015-11-25", "avg", "USD,EUR", http.StatusOK, []currencyDiff{
+			{Code: "USD", Name: "dolar amerykański", Ratio: "1", Average: &rateChange{From: "3,8280", To: "3,8290", Change: "0,0010", Percent: "0,0261"}},
+			{Code: "EUR", Name: "euro", Ratio: "1", Average: &rateChange{From: "4,2535", To: "4,2537", Change: "0,0002", Percent: "0,0047"}},
+		}, ""},
+		{"fall", "2015-11-30", "2015-11-25", "avg", "USD", http.StatusOK, []currencyDiff{
+			{Code: "USD", Name: "dolar amerykański", Ratio: "1", Average: &rateChange{From: "3,8602", To: "3,8290", Change: "-0,0312", Percent: "-0,8082"}},
+		}, ""},
+		{"buy and sell", "2015-11-24", "2015-11-28", "both", "USD", http.StatusOK, []currencyDiff{
+			{Code: "USD", Name: "dolar amerykański", Ratio: "1",
+				Buy:  &rateChange{From: "3,7897", To: "3,8099", Change: "0,0202", Percent: "0,5330"},
+				Sell: &rateChange{From: "3,8663", To: "3,8869", Change: "0,0206", Percent: "0,5328"}},
+		}, "No table published on 2015-11-28: weekend"},
+		// Only the currencies in both tables are compared
+		{"unknown code", "2015-11-24", "2015-11-25", "avg", "XYZ", http.StatusOK, []currencyDiff{}, ""},
+		{"wrong type", "2015-11-24", "2015-11-25", "x", "USD", http.StatusBadRequest, nil, ""},
+		{"wrong date", "2015-11-24", "x", "avg", "USD", http.StatusBadRequest, nil, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(DiffHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{
+			{Key: "date1", Value: tt.date1}, {Key: "date2", Value: tt.date2}, {Key: "type", Value: tt.rType}, {Key: "code", Value: tt.code}})
+
+		if w.Code != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var res struct {
+			Data rateDiff
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(res.Data.Currencies, tt.want) || res.Data.To.Notice != tt.notice {
+			t.Errorf("%s: %s, want %+v with notice %q", tt.name, w.Body, tt.want, tt.notice)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -10,9 +13,29 @@ import (
 // badRequest is handled by setting the status code in the reply to StatusBadRequest.
 type badRequest struct{ error }
 
+// unauthorized is handled by setting the status code in the reply to StatusUnauthorized.
+type unauthorized struct{ error }
+
+// invalidKey is handled by setting the status code in the reply to StatusUnauthorized.
+type invalidKey struct{ error }
+
+// tooManyRequests is handled by setting the status code in the reply to StatusTooManyRequests
+// and Retry-After header to the seconds the client has to wait.
+type tooManyRequests struct {
+	error
+	retryAfter time.Duration
+}
+
 // notFound is handled by setting the status code in the reply to StatusNotFound.
 type notFound struct{ error }
 
+// notPublished is handled by setting the status code in the reply to StatusNotFound
+// and Retry-After header to the seconds until the table is expected, if it's still ahead.
+type notPublished struct {
+	error
+	retryAfter time.Duration
+}
+
 // errorHandler wraps a function returning an error by handling the error and returning a http.Handler.
 // If the error is of the one of the types defined above, it is handled as described for every type.
 // If the error is of another type, it is considered as an internal error and its message is logged.
@@ -26,8 +49,22 @@ func errorHandler(f func(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		switch err.(type) {
 		case badRequest:
 			handleOutput(w, http.StatusBadRequest, err.Error())
+		case unauthorized:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			handleOutput(w, http.StatusUnauthorized, err.Error())
+		case invalidKey:
+			handleOutput(w, http.StatusUnauthorized, err.Error())
+		case tooManyRequests:
+			secs := math.Ceil(err.(tooManyRequests).retryAfter.Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(secs)))
+			handleOutput(w, http.StatusTooManyRequests, err.Error())
 		case notFound:
 			handleOutput(w, http.StatusNotFound, "not found")
+		case notPublished:
+			if wait := err.(notPublished).retryAfter; wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			}
+			handleOutput(w, http.StatusNotFound, err.Error())
 		default:
 			log.Println(err)
 			handleOutput(w, http.StatusInternalServerError, "oops")
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// GoldHandler returns the gold price for the given date.
+// When there was no price published that day, the price from the last publication day is returned.
+func GoldHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	date, err := parseDate(p.ByName("date"), svc.GoldMinDate)
+	if err != nil {
+		return err
+	}
+
+	res, err := svc.LastGoldPrice(gold, date)
+	if err == svc.ErrNotPublished {
+		return badRequest{errors.New("Resource for given date was not found")}
+	}
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
+
+// GoldRangeHandler returns the gold prices published between two dates.
+// The route shares the first parameter with GoldHandler, as httprouter requires, so "date" is the start of the period.
+// The prices are written as they're fetched, as JSend JSON or as CSV, the same as RangeHandler writes the tables.
+func GoldRangeHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	from, err := parseDate(p.ByName("date"), svc.GoldMinDate)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(p.ByName("to"), svc.GoldMinDate)
+	if err != nil {
+		return err
+	}
+	if err := svc.CheckPeriod(from, to); err != nil {
+		return badRequest{err}
+	}
+
+	csvOut, err := wantsCSV(r)
+	if err != nil {
+		return err
+	}
+	var out goldWriter = &jsonGold{jsonSeries{w: w}}
+	if csvOut {
+		out = &csvGold{csvSeries{w: w, cw: csv.NewWriter(w)}}
+	}
+
+	return stream(out, func(begin func() error) error {
+		return svc.EachGoldPrice(gold, from, to, func(gp svc.GoldPrice) error {
+			if err := begin(); err != nil {
+				return err
+			}
+			return out.write(gp)
+		})
+	})
+}
+
+// goldWriter writes the gold prices of the period one by one.
+type goldWriter interface {
+	series
+	write(p svc.GoldPrice) error
+}
+
+// jsonGold writes the prices as JSend response.
+type jsonGold struct {
+	jsonSeries
+}
+
+func (s *jsonGold) write(p svc.GoldPrice) error {
+	return s.add(p)
+}
+
+// csvGold writes a row per price.
+type csvGold struct {
+	csvSeries
+}
+
+func (s *csvGold) start() error {
+	s.w.Header().Set("Content-Type", "text/csv;charset=utf-8")
+	return s.cw.Write([]string{"date", "price"})
+}
+
+func (s *csvGold) write(p svc.GoldPrice) error {
+	if err := s.cw.Write([]string{p.Date, p.Price}); err != nil {
+		return err
+	}
+	s.cw.Flush()
+	return s.cw.Error()
+}
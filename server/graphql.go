@@ -0,0 +1,430 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/graphql"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var currencyType = &graphql.Object{
+	Name: "Currency",
+	Fields: map[string]*graphql.Field{
+		"code":          {},
+		"name":          {},
+		"ratio":         {},
+		"average":       {},
+		"buy":           {},
+		"sell":          {},
+		"mid":           {},
+		"spread":        {},
+		"spreadPercent": {},
+	},
+}
+
+var tableType = &graphql.Object{
+	Name: "Table",
+	Fields: map[string]*graphql.Field{
+		"date":   {},
+		"number": {},
+		"type":   {},
+		"notice": {},
+		"currencies": {
+			Type:    currencyType,
+			Args:    map[string]graphql.Arg{"codes": {}},
+			Resolve: resolveCurrencies,
+		},
+		"currency": {
+			Type:    currencyType,
+			Args:    map[string]graphql.Arg{"code": {Required: true}},
+			Resolve: resolveCurrency,
+		},
+	},
+}
+
+var rateType = &graphql.Object{
+	Name: "Rate",
+	Fields: map[string]*graphql.Field{
+		"date":          {},
+		"table":         {},
+		"code":          {},
+		"name":          {},
+		"ratio":         {},
+		"average":       {},
+		"buy":           {},
+		"sell":          {},
+		"mid":           {},
+		"spread":        {},
+		"spreadPercent": {},
+	},
+}
+
+var conversionType = &graphql.Object{
+	Name: "Conversion",
+	Fields: map[string]*graphql.Field{
+		"amount": {},
+		"from":   {},
+		"to":     {},
+		"result": {},
+		"date":   {},
+		"table":  {},
+	},
+}
+
+const (
+	// maxGraphQLQuery is the longest query, with its variables, accepted by /graphql.
+	maxGraphQLQuery = 64 << 10
+	// maxGraphQLFields is how many root fields a query can select.
+	maxGraphQLFields = 10
+)
+
+// graphqlSchema is served at /v1/graphql:
+//
+//	table(date: String!, type: String = "avg", codes: [String]): Table
+//	latest(type: String = "avg", codes: [String]): Table
+//	range(from: String!, to: String!, type: String = "avg", codes: [String]): [Table]
+//	rates(code: String!, from: String!, to: String!, type: String = "avg"): [Rate]
+//	convert(amount: String!, from: String!, to: String!, date: String): Conversion
+var graphqlSchema = &graphql.Schema{
+	Query: &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"table": {
+				Type: tableType,
+				Args: map[string]graphql.Arg{
+					"date":  {Required: true},
+					"type":  {Default: "avg"},
+					"codes": {},
+				},
+				Resolve: resolveTable,
+			},
+			"latest": {
+				Type: tableType,
+				Args: map[string]graphql.Arg{
+					"type":  {Default: "avg"},
+					"codes": {},
+				},
+				Resolve: resolveLatest,
+			},
+			"range": {
+				Type: tableType,
+				Args: map[string]graphql.Arg{
+					"from":  {Required: true},
+					"to":    {Required: true},
+					"type":  {Default: "avg"},
+					"codes": {},
+				},
+				Resolve: resolveRange,
+			},
+			"rates": {
+				Type: rateType,
+				Args: map[string]graphql.Arg{
+					"code": {Required: true},
+					"from": {Required: true},
+					"to":   {Required: true},
+					"type": {Default: "avg"},
+				},
+				Resolve: resolveRates,
+			},
+			"convert": {
+				Type: conversionType,
+				Args: map[string]graphql.Arg{
+					"amount": {Required: true},
+					"from":   {Required: true},
+					"to":     {Required: true},
+					"date":   {},
+				},
+				Resolve: resolveConvert,
+			},
+		},
+	},
+	MaxFields: maxGraphQLFields,
+}
+
+// GraphQLHandler executes the GraphQL query given as JSON {query, variables, operationName} in the body of POST request,
+// or as query, variables and operationName parameters of GET request.
+// As the GraphQL clients expect, the response is {data, errors} and not JSend.
+func GraphQLHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	tooLong := badRequest{fmt.Errorf("Given query is too long. Use at most %d KiB with the variables", maxGraphQLQuery>>10)}
+
+	var req graphql.Request
+	if r.Method == "GET" {
+		q := r.URL.Query()
+		if len(q.Get("query"))+len(q.Get("variables")) > maxGraphQLQuery {
+			return tooLong
+		}
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return badRequest{errors.New("Given variables are wrong. Use JSON object")}
+			}
+		}
+	} else {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLQuery))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return tooLong
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			return badRequest{errors.New("Given request is wrong. Send JSON {query, variables, operationName}")}
+		}
+	}
+
+	// Every field of Query costs as much as a request, the first one is paid already
+	var chargeErr error
+	req.Admit = func(fields int) error {
+		chargeErr = charge(r, fields-1)
+		return chargeErr
+	}
+	res := graphqlSchema.Execute(req)
+	if chargeErr != nil {
+		return chargeErr
+	}
+
+	code := http.StatusOK
+	if res.Data == nil {
+		code = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func resolveTable(p graphql.Params) (interface{}, error) {
+	date, err := parseDate(argString(p, "date"), svc.MinDate)
+	if err != nil {
+		return nil, err
+	}
+	rType := argString(p, "type")
+	t, err := graphqlTable(date, rType, argCodes(p, "codes"))
+	if err != nil {
+		return nil, err
+	}
+	if err := notYetPublished(date, rType, t); err != nil {
+		return nil, err
+	}
+	return tableMap(rType, t), nil
+}
+
+func resolveLatest(p graphql.Params) (interface{}, error) {
+	rType := argString(p, "type")
+	t, err := graphqlTable(calendar.Today(), rType, argCodes(p, "codes"))
+	if err != nil {
+		return nil, err
+	}
+	return tableMap(rType, t), nil
+}
+
+func graphqlTable(date time.Time, rType string, codes string) (table, error) {
+	if rType != "avg" && rType != "both" {
+		return table{}, errors.New("Given type is wrong. Use 'avg' or 'both'")
+	}
+	return lastTable(date, rType, codes)
+}
+
+func resolveRange(p graphql.Params) (interface{}, error) {
+	rType := argString(p, "type")
+	tables, err := graphqlRange(p, rType, argCodes(p, "codes"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0, len(tables))
+	for _, q := range tables {
+		res = append(res, tableMap(rType, table{Query: q}))
+	}
+	return res, nil
+}
+
+// resolveRates returns the rates of a single currency from the tables published in the period.
+func resolveRates(p graphql.Params) (interface{}, error) {
+	code := argString(p, "code")
+	tables, err := graphqlRange(p, argString(p, "type"), code)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0, len(tables))
+	for _, q := range tables {
+		for _, c := range q.Currencies {
+			res = append(res, map[string]interface{}{
+				"date":          q.FromData,
+				"table":         q.TableNumber,
+				"code":          c.Code,
+				"name":          c.Name,
+				"ratio":         c.Ratio,
+				"average":       c.Average,
+				"buy":           c.Buy,
+				"sell":          c.Sell,
+				"mid":           c.Mid,
+				"spread":        c.Spread,
+				"spreadPercent": c.SpreadPercent,
+			})
+		}
+	}
+	return res, nil
+}
+
+func graphqlRange(p graphql.Params, rType string, codes string) ([]svc.Query, error) {
+	from, err := parseDate(argString(p, "from"), svc.MinDate)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseDate(argString(p, "to"), svc.MinDate)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.CheckPeriod(from, to); err != nil {
+		return nil, err
+	}
+	if rType != "avg" && rType != "both" {
+		return nil, errors.New("Given type is wrong. Use 'avg' or 'both'")
+	}
+
+	tables := []svc.Query{}
+	err = svc.EachTable(backend, from, to, rType, codes, func(q svc.Query) error {
+		if rType == "both" {
+			q = svc.Spreads(q)
+		}
+		tables = append(tables, q)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("There was some problem with your request")
+	}
+	return tables, nil
+}
+
+// resolveConvert converts the amount using the average rates from table A published on the date or before it.
+func resolveConvert(p graphql.Params) (interface{}, error) {
+	amount, err := svc.ParseDecimal(argString(p, "amount"))
+	if err != nil {
+		return nil, errors.New("Given amount is wrong. Use a decimal number")
+	}
+	from, to := strings.ToUpper(argString(p, "from")), strings.ToUpper(argString(p, "to"))
+
+	date := calendar.Today()
+	if s := argString(p, "date"); s != "" {
+		if date, err = parseDate(s, svc.MinDate); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := lastTable(date, "avg", "*")
+	if err != nil {
+		return nil, err
+	}
+	res, err := svc.Convert(t.Query, amount, from, to)
+	if err == svc.ErrNoCurrency {
+		return nil, errors.New("Currency " + from + " or " + to + " not found in table " + t.TableNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"amount": argString(p, "amount"),
+		"from":   from,
+		"to":     to,
+		"result": svc.FormatDecimal(res, 2),
+		"date":   t.FromData,
+		"table":  t.TableNumber,
+	}, nil
+}
+
+func resolveCurrencies(p graphql.Params) (interface{}, error) {
+	all, _ := p.Source.(map[string]interface{})["currencies"].([]map[string]interface{})
+	codes := argCodes(p, "codes")
+	if codes == "*" {
+		return all, nil
+	}
+
+	res := []map[string]interface{}{}
+	for _, c := range all {
+		for _, code := range strings.Split(codes, ",") {
+			if c["code"] == code {
+				res = append(res, c)
+			}
+		}
+	}
+	return res, nil
+}
+
+func resolveCurrency(p graphql.Params) (interface{}, error) {
+	all, _ := p.Source.(map[string]interface{})["currencies"].([]map[string]interface{})
+	for _, c := range all {
+		if c["code"] == argString(p, "code") {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// tableMap returns the fields of Table type.
+func tableMap(rType string, t table) map[string]interface{} {
+	currencies := make([]map[string]interface{}, 0, len(t.Currencies))
+	for _, c := range t.Currencies {
+		currencies = append(currencies, map[string]interface{}{
+			"code":          c.Code,
+			"name":          c.Name,
+			"ratio":         c.Ratio,
+			"average":       c.Average,
+			"buy":           c.Buy,
+			"sell":          c.Sell,
+			"mid":           c.Mid,
+			"spread":        c.Spread,
+			"spreadPercent": c.SpreadPercent,
+		})
+	}
+	return map[string]interface{}{
+		"date":       t.FromData,
+		"number":     t.TableNumber,
+		"type":       rType,
+		"notice":     t.Notice,
+		"currencies": currencies,
+	}
+}
+
+func argString(p graphql.Params, name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+// argCodes returns the list of codes given in the argument the way svc expects them, "*" for all.
+func argCodes(p graphql.Params, name string) string {
+	var codes []string
+	switch v := p.Args[name].(type) {
+	case string:
+		codes = append(codes, v)
+	case []interface{}:
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				codes = append(codes, s)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		return "*"
+	}
+	return strings.Join(codes, ",")
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// countingBackend returns no tables and counts the months asked for.
+type countingBackend struct {
+	calls int
+}
+
+func (b *countingBackend) Table(date time.Time, sType string, code string) (svc.Query, error) {
+	b.calls++
+	return svc.Query{}, svc.ErrNotPublished
+}
+
+func (b *countingBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]svc.Query, error) {
+	b.calls++
+	return []svc.Query{{FromData: from.Format("2006-01-02")}}, nil
+}
+
+// manyFields returns the selection of n fields with distinct aliases, so they aren't merged.
+func manyFields(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, " f%d: latest { date }", i)
+	}
+	return b.String()
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	long := `{ latest { date } }` + strings.Repeat(" ", maxGraphQLQuery)
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		code        int
+		message     string
+	}{
+		{"post too long", "POST", "application/json", `{"query": "` + long + `"}`, http.StatusBadRequest, "Given query is too long"},
+		{"graphql too long", "POST", "application/graphql", long, http.StatusBadRequest, "Given query is too long"},
+		{"get too long", "GET", "", long, http.StatusBadRequest, "Given query is too long"},
+		{"post malformed", "POST", "application/json", `{"query": `, http.StatusBadRequest, "Given request is wrong"},
+		{"too many fields", "POST", "application/graphql", "{" + manyFields(maxGraphQLFields+1) + "}",
+			http.StatusBadRequest, "at most 10 are allowed"},
+		{"too deep", "POST", "application/graphql", strings.Repeat("{ latest ", 100) + strings.Repeat("}", 100),
+			http.StatusBadRequest, "nested too deeply"},
+		{"range too long", "POST", "application/graphql", `{ range(from: "2014-01-01", to: "2015-01-02") { date } }`,
+			http.StatusOK, "The period can't be longer than 366 days"},
+	}
+
+	b := &countingBackend{}
+	defer func(prev svc.Backend) { backend = prev }(backend)
+	backend = b
+
+	for _, tt := range tests {
+		var r *http.Request
+		if tt.method == "GET" {
+			r = httptest.NewRequest("GET", "/v1/graphql?query="+url.QueryEscape(tt.body), nil)
+		} else {
+			r = httptest.NewRequest("POST", "/v1/graphql", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		errorHandler(GraphQLHandler)(w, r, nil)
+
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("%s: %d %s, want %d with %q", tt.name, w.Code, w.Body, tt.code, tt.message)
+		}
+	}
+	if b.calls != 0 {
+		t.Errorf("backend was called %d times, want none", b.calls)
+	}
+}
+
+func TestGraphQLRange(t *testing.T) {
+	b := &countingBackend{}
+	defer func(prev svc.Backend) { backend = prev }(backend)
+	backend = b
+
+	r := httptest.NewRequest("POST", "/v1/graphql", strings.NewReader(`{ range(from: "2015-01-01", to: "2015-12-31") { date } }`))
+	r.Header.Set("Content-Type", "application/graphql")
+	w := httptest.NewRecorder()
+	if err := GraphQLHandler(w, r, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		Data struct {
+			Range []struct{ Date string }
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	// The tables are fetched a month at a time
+	if b.calls != 12 || len(res.Data.Range) != 12 || res.Data.Range[11].Date != "2015-12-01" {
+		t.Errorf("range made %d calls and returned %+v, want 12 months", b.calls, res.Data.Range)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// findEntry resolves the table number with the dir index, see svc.FindEntry. The tests use their own index.
+var findEntry = svc.FindEntry
+
+// TableHandler returns the table with the number given like 229-A-NBP-2015, as documents cite "Tabela nr 229/A/NBP/2015".
+// The number is resolved to the publication date with the dir index, so any backend can fetch the table.
+func TableHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	number := strings.ToUpper(strings.Replace(p.ByName("number"), "-", "/", -1))
+
+	e, err := findEntry(number)
+	if err == svc.ErrBadTableNumber {
+		return badRequest{errors.New("Given table number is wrong. Use e.g. '229-A-NBP-2015'")}
+	}
+	if err == svc.ErrNotPublished {
+		return notFound{}
+	}
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+
+	rType := e.TableType()
+	if rType == "" {
+		return badRequest{errors.New("Given table number is wrong. Only tables A and C are supported")}
+	}
+
+	q, err := backend.Table(e.Date, rType, "*")
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+	if rType == "both" {
+		q = svc.Spreads(q)
+	}
+
+	handleOutput(w, http.StatusOK, table{Query: q})
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestTableHandler(t *testing.T) {
+	defer withStub()()
+	defer func(f func(string) (svc.Entry, error)) { findEntry = f }(findEntry)
+
+	date := time.Date(2015, 11, 25, 0, 0, 0, 0, time.UTC)
+	index := map[string]svc.Entry{
+		"229/A/NBP/2015": {File: "a229z151125", Type: "a", Number: 229, Date: date},
+		"229/C/NBP/2015": {File: "c229z151125", Type: "c", Number: 229, Date: date},
+		"229/B/NBP/2015": {File: "b229z151125", Type: "b", Number: 229, Date: date},
+	}
+	// The numbers not in the index are checked by svc.FindEntry, the tests only give the ones it rejects without the index
+	findEntry = func(number string) (svc.Entry, error) {
+		if e, ok := index[number]; ok {
+			return e, nil
+		}
+		return svc.FindEntry(number)
+	}
+
+	tests := []struct {
+		number string
+		status int
+		want   string
+	}{
+		{"229-A-NBP-2015", http.StatusOK, `"fromDate":"2015-11-25","tableNumber":"229/A/NBP/2015","currencies":[{"code":"USD","name":"dolar amerykański","ratio":"1","average":"3,8290"`},
+		{"229-c-nbp-2015", http.StatusOK, `{"code":"USD","name":"dolar amerykański","ratio":"1","average":"","buy":"3,7907","sell":"3,8673","mid":"3,82900","spread":"0,0766"`},
+		{"229-B-NBP-2015", http.StatusBadRequest, "Only tables A and C are supported"},
+		{"229-A-2015", http.StatusBadRequest, "Given table number is wrong. Use e.g. '229-A-NBP-2015'"},
+		{"0-A-NBP-2015", http.StatusBadRequest, "Given table number is wrong"},
+		{"229-A-NBP-2001", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(TableHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{{Key: "number", Value: tt.number}})
+
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: %d %s, want %d with %s", tt.number, w.Code, w.Body, tt.status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The types below are the parts of OpenAPI 3 document used to describe the API.
+// See https://spec.openapis.org/oas/v3.0.3
+
+type operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Example     interface{}        `json:"example,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	AllOf       []*schema          `json:"allOf,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+func ref(name string) *schema { return &schema{Ref: "#/components/schemas/" + name} }
+
+func arrayOf(s *schema) *schema { return &schema{Type: "array", Items: s} }
+
+func object(required []string, props map[string]*schema) *schema {
+	return &schema{Type: "object", Required: required, Properties: props}
+}
+
+var (
+	stringSchema = &schema{Type: "string"}
+	dateSchema   = &schema{Type: "string", Format: "date", Description: "YYYY-MM-DD", Example: "2015-11-25"}
+	dateInSchema = &schema{Type: "string", Example: "2015-11-25",
+		Description: "YYYY-MM-DD, DD.MM.YYYY, YYYY-Www-D (ISO week date), YYYY-MM (the last publication day of the month), today, yesterday, last-business-day or -Nd (N days ago), in Warsaw time"}
+	decimalSchema = &schema{Type: "string", Pattern: `^(\d+(,\d+)?)?$`, Description: "Decimal number with decimal comma, as NBP publishes it, or empty when not quoted", Example: "3,9860"}
+	typeSchema    = &schema{Type: "string", Enum: []string{"avg", "both"}, Description: "avg for average rates (table A), both for buy and sell rates (table C)"}
+)
+
+// Parameters of the routes.
+var (
+	dateParam = parameter{Name: "date", In: "path", Required: true, Schema: dateInSchema,
+		Description: "Date of the table. When no table was published that day, the last one before it is used"}
+	fromParam = parameter{Name: "from", In: "path", Required: true, Schema: dateInSchema, Description: "First day of the period"}
+	toParam   = parameter{Name: "to", In: "path", Required: true, Schema: dateInSchema, Description: "Last day of the period"}
+	typeParam = parameter{Name: "type", In: "path", Required: true, Schema: typeSchema}
+	codeParam = parameter{Name: "code", In: "path", Required: true,
+		Schema:      &schema{Type: "string", Pattern: `^(\*|[A-Z]{3}(,[A-Z]{3})*)$`, Example: "USD,EUR"},
+		Description: "Comma separated ISO 4217 currency codes, or * for all currencies"}
+	yearParam = parameter{Name: "year", In: "path", Required: true, Schema: &schema{Type: "string", Pattern: `^\d{4}$`, Example: "2015"}}
+	idParam   = parameter{Name: "id", In: "path", Required: true, Schema: stringSchema, Description: "Id of the webhook"}
+)
+
+// schemas are the component schemas referenced by the routes.
+var schemas = map[string]*schema{
+	"Currency": object([]string{"code", "name", "ratio", "average", "buy", "sell"}, map[string]*schema{
+		"code":          {Type: "string", Example: "USD"},
+		"name":          {Type: "string", Example: "dolar amerykański"},
+		"ratio":         {Type: "string", Description: "Number of units of the currency the rates are quoted for", Example: "1"},
+		"average":       {Ref: decimalRef, Description: "Average rate, empty in table C"},
+		"buy":           {Ref: decimalRef, Description: "Buy rate, empty in table A"},
+		"sell":          {Ref: decimalRef, Description: "Sell rate, empty in table A"},
+		"mid":           {Ref: decimalRef, Description: "Mean of buy and sell rates, only in table C"},
+		"spread":        {Ref: decimalRef, Description: "Sell rate minus buy rate, only in table C"},
+		"spreadPercent": {Ref: decimalRef, Description: "Spread as the percentage of the mid price, only in table C"},
+	}),
+	"Diff": object([]string{"from", "to", "currencies"}, map[string]*schema{
+		"from": ref("TableRef"),
+		"to":   ref("TableRef"),
+		"currencies": arrayOf(object([]string{"code", "name", "ratio"}, map[string]*schema{
+			"code":    {Type: "string", Example: "USD"},
+			"name":    stringSchema,
+			"ratio":   stringSchema,
+			"average": ref("RateChange"),
+			"buy":     ref("RateChange"),
+			"sell":    ref("RateChange"),
+		})),
+	}),
+	"TableRef": object([]string{"date", "fromDate", "tableNumber"}, map[string]*schema{
+		"date":        {Type: "string", Format: "date", Description: "Requested date, normalized", Example: "2015-11-25"},
+		"fromDate":    dateSchema,
+		"tableNumber": {Type: "string", Example: "229/A/NBP/2015"},
+		"notice":      {Type: "string", Description: "Why the table from another date is used"},
+	}),
+	"RateChange": object([]string{"from", "to", "change", "percent"}, map[string]*schema{
+		"from":    ref("Decimal"),
+		"to":      ref("Decimal"),
+		"change":  {Type: "string", Description: "to minus from, with decimal comma", Example: "-0,0120"},
+		"percent": {Type: "string", Description: "Change as the percentage of from, with decimal comma", Example: "-0,3134"},
+	}),
+	"PeriodAverage": object([]string{"period", "fromDate", "toDate", "tables", "firstTable", "lastTable", "currencies"}, map[string]*schema{
+		"period":     {Type: "string", Example: "2015-11"},
+		"fromDate":   dateSchema,
+		"toDate":     dateSchema,
+		"tables":     {Type: "integer", Description: "Number of tables the averages are computed from"},
+		"firstTable": {Type: "string", Example: "213/A/NBP/2015"},
+		"lastTable":  {Type: "string", Example: "232/A/NBP/2015"},
+		"currencies": arrayOf(object([]string{"code", "name", "ratio", "average", "tables"}, map[string]*schema{
+			"code":    {Type: "string", Example: "USD"},
+			"name":    stringSchema,
+			"ratio":   stringSchema,
+			"average": ref("Decimal"),
+			"tables":  {Type: "integer", Description: "Number of tables quoting the currency"},
+		})),
+	}),
+	"SpreadSeries": object([]string{"code", "name", "points", "minPercent", "maxPercent", "averagePercent"}, map[string]*schema{
+		"code": {Type: "string", Example: "USD"},
+		"name": stringSchema,
+		"points": arrayOf(object([]string{"date", "tableNumber", "buy", "sell", "mid", "spread", "spreadPercent"}, map[string]*schema{
+			"date":          dateSchema,
+			"tableNumber":   {Type: "string", Example: "229/C/NBP/2015"},
+			"buy":           ref("Decimal"),
+			"sell":          ref("Decimal"),
+			"mid":           ref("Decimal"),
+			"spread":        ref("Decimal"),
+			"spreadPercent": ref("Decimal"),
+		})),
+		"minPercent":     ref("Decimal"),
+		"maxPercent":     ref("Decimal"),
+		"averagePercent": ref("Decimal"),
+	}),
+	"Decimal": decimalSchema,
+	"Currencies": object([]string{"date", "fromDate", "tableNumber", "currencies"}, map[string]*schema{
+		"date":        {Type: "string", Format: "date", Description: "Requested date, normalized", Example: "2015-11-25"},
+		"fromDate":    dateSchema,
+		"tableNumber": {Type: "string", Example: "229/A/NBP/2015"},
+		"currencies": arrayOf(object([]string{"code", "name"}, map[string]*schema{
+			"code": {Type: "string", Example: "USD"},
+			"name": {Type: "string", Example: "dolar amerykański"},
+		})),
+		"notice": {Type: "string", Description: "Why the table from another date is returned"},
+	}),
+	"Table": object([]string{"fromDate", "tableNumber", "currencies"}, map[string]*schema{
+		"date":        {Type: "string", Format: "date", Description: "Requested date, normalized", Example: "2015-11-25"},
+		"fromDate":    dateSchema,
+		"tableNumber": {Type: "string", Example: "229/A/NBP/2015"},
+		"currencies":  arrayOf(ref("Currency")),
+		"notice":      {Type: "string", Description: "Why the table from another date is returned", Example: "No table published on 2015-12-25: holiday (Boże Narodzenie)"},
+		"base":        {Type: "string", Description: "Currency the rates are expressed in, when it's not PLN", Example: "EUR"},
+	}),
+	"CrossRates": object([]string{"date", "fromDate", "tableNumber", "codes", "rates"}, map[string]*schema{
+		"date":        {Type: "string", Format: "date", Description: "Requested date, normalized", Example: "2015-11-25"},
+		"fromDate":    dateSchema,
+		"tableNumber": {Type: "string", Example: "229/A/NBP/2015"},
+		"codes":       arrayOf(stringSchema),
+		"rates": {Type: "object", Description: "rates[from][to] is the amount of to currency for 1 unit of from currency",
+			Example: map[string]map[string]string{"USD": {"USD": "1,000000", "EUR": "0,940510"}, "EUR": {"USD": "1,063252", "EUR": "1,000000"}}},
+		"notice": {Type: "string", Description: "Why the table from another date is returned"},
+	}),
+	"TaxRate": {AllOf: []*schema{ref("Table"), object([]string{"date", "annotation"}, map[string]*schema{
+		"date":       dateSchema,
+		"annotation": {Type: "string", Example: "Kurs średni NBP z tabeli nr 229/A/NBP/2015 z dnia 24.11.2015"},
+	})}},
+	"GoldPrice": object([]string{"date", "price"}, map[string]*schema{
+		"date":  dateSchema,
+		"price": {Ref: decimalRef, Description: "Price of 1g of gold in PLN"},
+	}),
+	"Calendar": object([]string{"year", "holidays", "mismatches"}, map[string]*schema{
+		"year": {Type: "integer"},
+		"holidays": arrayOf(object([]string{"date", "name"}, map[string]*schema{
+			"date": dateSchema,
+			"name": stringSchema,
+		})),
+		"mismatches": arrayOf(object([]string{"date", "published"}, map[string]*schema{
+			"date":      dateSchema,
+			"published": {Type: "boolean", Description: "Whether the table was published on a day the calendar doesn't expect it"},
+		})),
+	}),
+	"BatchItem": object([]string{"date", "type"}, map[string]*schema{
+		"date":  dateSchema,
+		"type":  typeSchema,
+		"codes": arrayOf(stringSchema),
+	}),
+	"BatchResult": {AllOf: []*schema{ref("BatchItem"), object([]string{"status"}, map[string]*schema{
+		"status":  {Type: "string", Enum: []string{"success", "error"}},
+		"data":    ref("Table"),
+		"message": stringSchema,
+	})}},
+	"Webhook": object([]string{"url"}, map[string]*schema{
+		"id":     {Type: "string", Description: "Read only"},
+		"url":    {Type: "string", Format: "uri"},
+		"secret": {Type: "string", Description: "Key of HMAC-SHA256 signature, generated when not given. Returned only on registration"},
+		"types":  arrayOf(typeSchema),
+	}),
+	"GraphQLRequest": object([]string{"query"}, map[string]*schema{
+		"query":         stringSchema,
+		"variables":     {Type: "object"},
+		"operationName": stringSchema,
+	}),
+	"GraphQLResponse": object(nil, map[string]*schema{
+		"data": {Type: "object"},
+		"errors": arrayOf(object([]string{"message"}, map[string]*schema{
+			"message": stringSchema,
+			"path":    arrayOf(&schema{}),
+		})),
+	}),
+	"Error": object([]string{"status", "message"}, map[string]*schema{
+		"status":  {Type: "string", Enum: []string{"error"}},
+		"message": stringSchema,
+	}),
+}
+
+const decimalRef = "#/components/schemas/Decimal"
+
+// jsend returns the responses of the route returning the data in JSend envelope.
+func jsend(data *schema) map[string]*response {
+	props := map[string]*schema{"status": {Type: "string", Enum: []string{"success"}}}
+	required := []string{"status"}
+	if data != nil {
+		props["data"] = data
+		required = append(required, "data")
+	}
+	return map[string]*response{
+		"200": {Description: "Success", Content: map[string]mediaType{"application/json": {Schema: object(required, props)}}},
+		"400": {Ref: "#/components/responses/Error"},
+		"500": {Ref: "#/components/responses/Error"},
+	}
+}
+
+// jsonBody returns the request body of JSON type.
+func jsonBody(s *schema) *requestBody {
+	return &requestBody{Required: true, Content: map[string]mediaType{"application/json": {Schema: s}}}
+}
+
+// openAPI returns OpenAPI 3 document describing the routes.
+func openAPI(routes []route) map[string]interface{} {
+	paths := map[string]map[string]*operation{}
+	add := func(path string, method string, op operation) {
+		path = openAPIPath(path)
+		if paths[path] == nil {
+			paths[path] = map[string]*operation{}
+		}
+		paths[path][strings.ToLower(method)] = &op
+	}
+
+	for _, rt := range routes {
+		op := rt.doc
+		op.Security = security(rt)
+		if rt.admin || !rt.public && len(apiKeys) > 0 {
+			op.Responses = withResponse(op.Responses, "401", &response{Ref: "#/components/responses/Error"})
+		}
+		if !ipRate.Unlimited() || len(apiKeys) > 0 {
+			op.Responses = withResponse(op.Responses, "429", &response{Ref: "#/components/responses/Error"})
+		}
+		if strings.Contains(rt.path, "/:id") {
+			op.Responses = withResponse(op.Responses, "404", &response{Ref: "#/components/responses/Error"})
+		}
+		add(rt.path, rt.method, op)
+
+		if rt.alias != "" {
+			op.Deprecated = true
+			op.Description = strings.TrimSpace("Deprecated alias of " + openAPIPath(rt.path) + ", will be removed on " + sunset.Format("2006-01-02") + ". " + op.Description)
+			add(rt.alias, rt.method, op)
+		}
+	}
+
+	components := map[string]interface{}{
+		"schemas": schemas,
+		"responses": map[string]*response{
+			"Error": {Description: "Error", Content: map[string]mediaType{"application/json": {Schema: ref("Error")}}},
+		},
+	}
+	schemes := map[string]interface{}{}
+	if adminToken != "" {
+		schemes["adminToken"] = map[string]string{"type": "http", "scheme": "bearer"}
+	}
+	if len(apiKeys) > 0 {
+		schemes["apiKeyHeader"] = map[string]string{"type": "apiKey", "in": "header", "name": apiKeyHeader}
+		schemes["apiKeyQuery"] = map[string]string{"type": "apiKey", "in": "query", "name": apiKeyParam}
+	}
+	if len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":       "NBP API",
+			"version":     "1.0.0",
+			"description": "Currency exchange rates and gold prices published by Narodowy Bank Polski. Responses follow JSend.",
+		},
+		"paths":      paths,
+		"components": components,
+	}
+}
+
+// withResponse returns a copy of the responses with the one set, so the routes sharing them aren't changed.
+func withResponse(rs map[string]*response, code string, r *response) map[string]*response {
+	res := map[string]*response{}
+	for k, v := range rs {
+		res[k] = v
+	}
+	res[code] = r
+	return res
+}
+
+// security returns the alternative sets of credentials the route accepts, nil when it doesn't need any.
+func security(rt route) []map[string][]string {
+	var keys []string
+	if !rt.public && len(apiKeys) > 0 {
+		keys = []string{"apiKeyHeader", "apiKeyQuery"}
+		if !requireKey {
+			// The key is optional
+			keys = append(keys, "")
+		}
+	}
+	admin := rt.admin && adminToken != ""
+
+	var res []map[string][]string
+	switch {
+	case len(keys) > 0:
+		for _, k := range keys {
+			req := map[string][]string{}
+			if k != "" {
+				req[k] = []string{}
+			}
+			if admin {
+				req["adminToken"] = []string{}
+			}
+			res = append(res, req)
+		}
+	case admin:
+		res = append(res, map[string][]string{"adminToken": {}})
+	}
+	return res
+}
+
+// openAPIPath converts httprouter path to OpenAPI path template, e.g. /gold/:date to /gold/{date}.
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// OpenAPIHandler returns OpenAPI 3 document describing the registered routes.
+// It's generated from the same routes RegisterHandlers registers, so it can't get out of date.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	return json.NewEncoder(w).Encode(openAPI(registered))
+}
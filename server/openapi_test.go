@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/karolgorecki/nbp/poller"
+	"github.com/karolgorecki/nbp/svc"
+	"github.com/karolgorecki/nbp/webhook"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// routerPaths returns "METHOD path" of every route registered in the router.
+// httprouter doesn't list its routes, so its trees are walked.
+func routerPaths(rt *httprouter.Router) []string {
+	var res []string
+	var walk func(method string, prefix string, n reflect.Value)
+	walk = func(method string, prefix string, n reflect.Value) {
+		if n.IsNil() {
+			return
+		}
+		n = n.Elem()
+		path := prefix + n.FieldByName("path").String()
+		if !n.FieldByName("handle").IsNil() {
+			res = append(res, method+" "+path)
+		}
+		children := n.FieldByName("children")
+		for i := 0; i < children.Len(); i++ {
+			walk(method, path, children.Index(i))
+		}
+	}
+
+	trees := reflect.ValueOf(rt).Elem().FieldByName("trees")
+	for _, method := range trees.MapKeys() {
+		walk(method.String(), "", trees.MapIndex(method))
+	}
+	return res
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	defer func(b svc.Backend, g svc.GoldBackend, w *webhook.Registry, p *poller.Poller, token string, rs []route) {
+		backend, gold, webhooks, publications, adminToken, registered = b, g, w, p, token, rs
+	}(backend, gold, webhooks, publications, adminToken, registered)
+
+	tests := []struct {
+		name string
+		c    Config
+	}{
+		{"default", Config{}},
+		// The webhooks aren't registered without the admin token
+		{"webhooks without token", Config{Webhooks: &webhook.Registry{}}},
+		{"all routes", Config{Webhooks: &webhook.Registry{}, Publications: &poller.Poller{}, AdminToken: "secret"}},
+	}
+	for _, tt := range tests {
+		h := RegisterHandlers(tt.c)
+		rt := h.(corsHandler).next.(compressHandler).next.(*httprouter.Router)
+		var got []string
+		for _, p := range append(routerPaths(rt), routerPaths(rt.NotFound.(*httprouter.Router))...) {
+			route := strings.SplitN(p, " ", 2)
+			got = append(got, route[0]+" "+openAPIPath(route[1]))
+		}
+		sort.Strings(got)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: /v1/openapi.json returned %d", tt.name, w.Code)
+		}
+		var doc struct {
+			Paths map[string]map[string]json.RawMessage
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var want []string
+		for path, ops := range doc.Paths {
+			for method := range ops {
+				want = append(want, strings.ToUpper(method)+" "+path)
+			}
+		}
+		sort.Strings(want)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: registered routes\n%s\nOpenAPI paths\n%s", tt.name, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RangeHandler returns the tables of given type published between two dates, filtered by code.
+// The tables are written as they're fetched, as JSend JSON or as CSV with a row per currency
+// when ?format=csv is given or text/csv is accepted, so the memory use doesn't grow with the period.
+func RangeHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	from, err := parseDate(p.ByName("from"), svc.MinDate)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(p.ByName("to"), svc.MinDate)
+	if err != nil {
+		return err
+	}
+	if err := svc.CheckPeriod(from, to); err != nil {
+		return badRequest{err}
+	}
+
+	rType := p.ByName("type")
+	if rType != "avg" && rType != "both" {
+		return badRequest{errors.New("Given type is wrong. Use 'avg' or 'both'")}
+	}
+
+	csvOut, err := wantsCSV(r)
+	if err != nil {
+		return err
+	}
+	var out seriesWriter = &jsonSeries{w: w}
+	if csvOut {
+		out = &csvSeries{w: w, cw: csv.NewWriter(w)}
+	}
+
+	return stream(out, func(begin func() error) error {
+		return svc.EachTable(backend, from, to, rType, p.ByName("code"), func(q svc.Query) error {
+			if err := begin(); err != nil {
+				return err
+			}
+			if rType == "both" {
+				q = svc.Spreads(q)
+			}
+			return out.write(q)
+		})
+	})
+}
+
+// stream writes the series with out, as each fetches its elements. each calls begin before writing an element.
+// The response is started with the first element, so the errors before it are still returned as JSend.
+func stream(out series, each func(begin func() error) error) error {
+	started := false
+	err := each(func() error {
+		if started {
+			return nil
+		}
+		started = true
+		return out.start()
+	})
+	if err != nil && !started {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+	if err != nil {
+		// Break the connection, so the client doesn't take the partial response as complete
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		if err := out.start(); err != nil {
+			return err
+		}
+	}
+	return out.end()
+}
+
+// wantsCSV reports whether the series is requested as CSV, with ?format=csv or by accepting text/csv.
+func wantsCSV(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("format") {
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), "text/csv"), nil
+	case "json":
+		return false, nil
+	case "csv":
+		return true, nil
+	}
+	return false, badRequest{errors.New("Given format is wrong. Use 'json' or 'csv'")}
+}
+
+// series starts and ends the response with the time series, its writer writes the elements.
+type series interface {
+	start() error
+	end() error
+}
+
+// seriesWriter writes the tables of the time series one by one.
+type seriesWriter interface {
+	series
+	write(q svc.Query) error
+}
+
+// jsonSeries writes the tables as JSend response, the same as handleOutput does.
+type jsonSeries struct {
+	w http.ResponseWriter
+	n int
+}
+
+func (s *jsonSeries) start() error {
+	s.w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	_, err := io.WriteString(s.w, `{"data":[`)
+	return err
+}
+
+func (s *jsonSeries) write(q svc.Query) error {
+	return s.add(q)
+}
+
+// add writes the next element of the data array.
+func (s *jsonSeries) add(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.n > 0 {
+		b = append([]byte{','}, b...)
+	}
+	s.n++
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *jsonSeries) end() error {
+	_, err := io.WriteString(s.w, "],\"status\":\"success\"}\n")
+	return err
+}
+
+// csvSeries writes a row per currency in every table.
+type csvSeries struct {
+	w  http.ResponseWriter
+	cw *csv.Writer
+}
+
+func (s *csvSeries) start() error {
+	s.w.Header().Set("Content-Type", "text/csv;charset=utf-8")
+	return s.cw.Write([]string{"date", "table", "code", "name", "ratio", "average", "buy", "sell", "mid", "spread", "spreadPercent"})
+}
+
+func (s *csvSeries) write(q svc.Query) error {
+	for _, c := range q.Currencies {
+		if err := s.cw.Write([]string{q.FromData, q.TableNumber, c.Code, c.Name, c.Ratio, c.Average, c.Buy, c.Sell, c.Mid, c.Spread, c.SpreadPercent}); err != nil {
+			return err
+		}
+	}
+	// Pass the rows on, so they aren't kept in the buffer
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+func (s *csvSeries) end() error {
+	s.cw.Flush()
+	return s.cw.Error()
+}
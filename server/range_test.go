@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// countingGold returns a price for every month asked for and counts the months.
+type countingGold struct {
+	calls int
+}
+
+func (g *countingGold) GoldPrice(date time.Time) (svc.GoldPrice, error) {
+	g.calls++
+	return svc.GoldPrice{}, svc.ErrNotPublished
+}
+
+func (g *countingGold) GoldPrices(from time.Time, to time.Time) ([]svc.GoldPrice, error) {
+	g.calls++
+	return []svc.GoldPrice{{Date: from.Format("2006-01-02"), Price: "128.97"}}, nil
+}
+
+func TestRangeHandlers(t *testing.T) {
+	b, g := &countingBackend{}, &countingGold{}
+	defer func(prevB svc.Backend, prevG svc.GoldBackend) { backend, gold = prevB, prevG }(backend, gold)
+	backend, gold = b, g
+
+	rangeParams := func(from, to string) httprouter.Params {
+		return httprouter.Params{{Key: "from", Value: from}, {Key: "to", Value: to}, {Key: "type", Value: "avg"}, {Key: "code", Value: "USD"}}
+	}
+	goldParams := func(from, to string) httprouter.Params {
+		return httprouter.Params{{Key: "date", Value: from}, {Key: "to", Value: to}}
+	}
+	tests := []struct {
+		name    string
+		handler handle
+		params  httprouter.Params
+		code    int
+		message string
+		// calls is how many months are fetched
+		calls int
+	}{
+		{"range", RangeHandler, rangeParams("2015-01-01", "2015-12-31"), http.StatusOK, `"fromDate":"2015-12-01"`, 12},
+		{"range leap year", RangeHandler, rangeParams("2016-01-01", "2016-12-31"), http.StatusOK, `"status":"success"`, 12},
+		{"range too long", RangeHandler, rangeParams("2015-01-01", "2016-01-02"), http.StatusBadRequest, "The period can't be longer than 366 days", 0},
+		{"range reversed", RangeHandler, rangeParams("2015-12-31", "2015-01-01"), http.StatusBadRequest, "The end date can't be before the start date", 0},
+		{"gold", GoldRangeHandler, goldParams("2015-01-01", "2015-12-31"), http.StatusOK, `"date":"2015-12-01"`, 12},
+		{"gold too long", GoldRangeHandler, goldParams("2013-01-02", "2020-01-01"), http.StatusBadRequest, "The period can't be longer than 366 days", 0},
+	}
+
+	for _, tt := range tests {
+		b.calls, g.calls = 0, 0
+		w := httptest.NewRecorder()
+		errorHandler(tt.handler)(w, httptest.NewRequest("GET", "/", nil), tt.params)
+
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("%s: %d %s, want %d with %q", tt.name, w.Code, w.Body, tt.code, tt.message)
+		}
+		if calls := b.calls + g.calls; calls != tt.calls {
+			t.Errorf("%s: %d months fetched, want %d", tt.name, calls, tt.calls)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/karolgorecki/nbp/limit"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The API key is given in the header or in the query parameter.
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyParam  = "api_key"
+)
+
+// limiter keeps the token buckets of the clients.
+var limiter = limit.New()
+
+// chargeKey is the key of the context value charging the client of the request, see charge.
+type chargeKey struct{}
+
+// limited checks the API key of the request, and limits the rate of the requests per key,
+// or per client IP for the requests without the key or with a wrong one.
+// Public routes don't need the key, even if it's required.
+// Every request costs one token, the handlers doing more work charge the rest with charge.
+func limited(f handle, public bool) handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			key = r.URL.Query().Get(apiKeyParam)
+		}
+
+		client, rate := "ip:"+clientIP(r), ipRate
+		if key != "" {
+			keyRate, ok := apiKeys[key]
+			if !ok {
+				// The wrong keys cost the client IP, so guessing them is limited too
+				if ok, wait := limiter.Allow(client, rate); !ok {
+					return tooManyRequests{errors.New("Too many requests. Slow down"), wait}
+				}
+				return invalidKey{errors.New("Given API key is wrong")}
+			}
+			client, rate = "key:"+key, keyRate
+		} else if requireKey && !public {
+			return invalidKey{errors.New("API key is required. Use '" + apiKeyHeader + "' header or '" + apiKeyParam + "' parameter")}
+		}
+
+		if ok, wait := limiter.Allow(client, rate); !ok {
+			return tooManyRequests{errors.New("Too many requests. Slow down"), wait}
+		}
+
+		take := func(n int) error {
+			// With the token taken above, the request costs n+1, which the bucket never holds
+			if !rate.Unlimited() && n >= rate.N {
+				return badRequest{fmt.Errorf("Given request is too big for the rate limit of %d requests per %v. Split it", rate.N, rate.Per)}
+			}
+			if ok, wait := limiter.AllowN(client, rate, n); !ok {
+				return tooManyRequests{errors.New("Too many requests. Slow down"), wait}
+			}
+			return nil
+		}
+		return f(w, r.WithContext(context.WithValue(r.Context(), chargeKey{}, take)), p)
+	}
+}
+
+// charge takes n more tokens from the bucket of the client of the request, for the requests costing as much as
+// many ones, like /batch with n+1 items. The request has to be rejected with the error when the client can't afford it.
+func charge(r *http.Request, n int) error {
+	take, ok := r.Context().Value(chargeKey{}).(func(int) error)
+	if !ok || n <= 0 {
+		return nil
+	}
+	return take(n)
+}
+
+// clientIP returns the IP address of the client. Behind a proxy, like Heroku router,
+// it's the last address in X-Forwarded-For header, the one added by the proxy.
+func clientIP(r *http.Request) string {
+	if trustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			addrs := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
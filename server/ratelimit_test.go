@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/limit"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// convertFields returns the selection of n convert fields failing before the backend is called.
+func convertFields(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, ` f%d: convert(amount: "x", from: "USD", to: "EUR") { result }`, i)
+	}
+	return "{" + b.String() + " }"
+}
+
+// batchItems returns the batch of n items failing before the backend is called.
+func batchItems(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = `{"date": "x", "type": "avg"}`
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func TestLimitedCharge(t *testing.T) {
+	defer func(l *limit.Limiter, r limit.Rate) { limiter, ipRate = l, r }(limiter, ipRate)
+	ipRate = limit.Rate{N: 5, Per: 5 * time.Minute}
+
+	batch := errorHandler(limited(BatchHandler, false))
+	graphQL := errorHandler(limited(GraphQLHandler, false))
+	tests := []struct {
+		name    string
+		handler httprouter.Handle
+		body    string
+		key     string
+		// reset starts with the full bucket
+		reset   bool
+		code    int
+		message string
+	}{
+		// A request costs a token per item
+		{"batch", batch, batchItems(3), "", true, http.StatusOK, ""},
+		// The rejected batch still costs a token
+		{"batch over limit", batch, batchItems(3), "", false, http.StatusTooManyRequests, "Too many requests"},
+		{"batch within limit", batch, batchItems(1), "", false, http.StatusOK, ""},
+		{"batch emptying bucket", batch, batchItems(5), "", true, http.StatusOK, ""},
+		{"batch too big for limit", batch, batchItems(6), "", true, http.StatusBadRequest, "too big for the rate limit of 5 requests per 5m0s"},
+		// and a token per field of Query
+		{"graphql", graphQL, convertFields(4), "", true, http.StatusOK, ""},
+		{"graphql over limit", graphQL, convertFields(2), "", false, http.StatusTooManyRequests, "Too many requests"},
+		{"graphql too big for limit", graphQL, convertFields(6), "", true, http.StatusBadRequest, "too big for the rate limit"},
+		// A wrong key costs the client IP a token
+		{"wrong key", batch, batchItems(1), "wrong", true, http.StatusUnauthorized, "API key is wrong"},
+		{"wrong key emptying bucket", batch, batchItems(4), "", false, http.StatusOK, ""},
+		{"wrong key over limit", batch, batchItems(1), "wrong", false, http.StatusTooManyRequests, "Too many requests"},
+	}
+
+	for _, tt := range tests {
+		if tt.reset {
+			limiter = limit.New()
+		}
+		r := httptest.NewRequest("POST", "/v1/batch", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/graphql")
+		r.RemoteAddr = "192.0.2.1:1234"
+		if tt.key != "" {
+			r.Header.Set(apiKeyHeader, tt.key)
+		}
+		w := httptest.NewRecorder()
+		tt.handler(w, r, nil)
+
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("%s: %d %s, want %d with %q", tt.name, w.Code, w.Body, tt.code, tt.message)
+		}
+		if tt.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", tt.name)
+		}
+	}
+}
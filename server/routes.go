@@ -0,0 +1,247 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The aliases are deprecated since the routes got /v1 prefix, and will be removed on sunset.
+var (
+	deprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset      = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// route is a route of the API. The routes are registered by RegisterHandlers and described by /v1/openapi.json.
+type route struct {
+	method string
+	path   string
+	// alias is the deprecated path the route was served at before the API was versioned, if any.
+	// The path has to end with the alias.
+	alias  string
+	handle handle
+	// admin routes require the admin token, when it's configured.
+	admin bool
+	// public routes don't require the API key.
+	public bool
+	// legacy is set for the alias consuming the whole path space, registered on its own router.
+	legacy bool
+	doc    operation
+}
+
+// routes returns the routes enabled by the configuration.
+func routes() []route {
+	rs := []route{
+		{method: "GET", path: "/v1/rates/:date/:type/:code", alias: "/:date/:type/:code", handle: IndexHandler, legacy: true, doc: operation{
+			Summary:     "Currency table",
+			Description: "With base, the average rates are expressed in the base currency instead of PLN, and PLN is added to the table. Today's table not published yet is 404 with the latest table number in the message.",
+			Tags:        []string{"rates"},
+			Parameters: []parameter{dateParam, typeParam, codeParam,
+				{Name: "base", In: "query", Schema: &schema{Type: "string", Example: "EUR"}, Description: "Currency the rates are expressed in, only with avg type"}},
+			Responses: withResponse(jsend(ref("Table")), "404", &response{Ref: "#/components/responses/Error"}),
+		}},
+		{method: "GET", path: "/v1/crossrates/:date/:code", handle: CrossRatesHandler, doc: operation{
+			Summary:     "Cross rates",
+			Description: "Rates between every two of the currencies, computed from the average rates in table A. PLN can be given too.",
+			Tags:        []string{"rates"},
+			Parameters:  []parameter{dateParam, codeParam},
+			Responses:   withResponse(jsend(ref("CrossRates")), "404", &response{Ref: "#/components/responses/Error"}),
+		}},
+		{method: "GET", path: "/v1/currencies/:date/:type", handle: CurrenciesHandler, doc: operation{
+			Summary:    "Currencies quoted in the table",
+			Tags:       []string{"rates"},
+			Parameters: []parameter{dateParam, typeParam},
+			Responses:  withResponse(jsend(ref("Currencies")), "404", &response{Ref: "#/components/responses/Error"}),
+		}},
+		{method: "GET", path: "/v1/range/:from/:to/:type/:code", alias: "/range/:from/:to/:type/:code", handle: RangeHandler, doc: operation{
+			Summary:     "Currency tables published in the period",
+			Description: "The period can be up to 366 days long. The tables are streamed as they're fetched. With format=csv, or Accept: text/csv, there is a row per currency in every table.",
+			Tags:        []string{"rates"},
+			Parameters: []parameter{fromParam, toParam, typeParam, codeParam,
+				{Name: "format", In: "query", Schema: &schema{Type: "string", Enum: []string{"json", "csv"}}}},
+			Responses: seriesResponses(ref("Table"), "date,table,code,name,ratio,average,buy,sell,mid,spread,spreadPercent"),
+		}},
+		{method: "GET", path: "/v1/diff/:date1/:date2/:type/:code", handle: DiffHandler, doc: operation{
+			Summary:     "Change of the rates between two dates",
+			Description: "Rates from the tables used for both dates, with the absolute and percentage change. Only the currencies quoted in both tables are compared.",
+			Tags:        []string{"rates"},
+			Parameters: []parameter{
+				{Name: "date1", In: "path", Required: true, Schema: dateInSchema, Description: "Date of the first table, e.g. of the invoice"},
+				{Name: "date2", In: "path", Required: true, Schema: dateInSchema, Description: "Date of the second table, e.g. of the payment"},
+				typeParam, codeParam,
+			},
+			Responses: withResponse(jsend(ref("Diff")), "404", &response{Ref: "#/components/responses/Error"}),
+		}},
+		{method: "GET", path: "/v1/table/:number", handle: TableHandler, doc: operation{
+			Summary:     "Table with given number",
+			Description: "All currencies of the table cited by its number, e.g. 229/A/NBP/2015 given as 229-A-NBP-2015. Only tables A and C are supported.",
+			Tags:        []string{"rates"},
+			Parameters: []parameter{
+				{Name: "number", In: "path", Required: true, Schema: &schema{Type: "string", Pattern: `^\d+-[ACac]-NBP-\d{4}$`, Example: "229-A-NBP-2015"}, Description: "Number of the table with dashes instead of slashes"},
+			},
+			Responses: withResponse(jsend(ref("Table")), "404", &response{Ref: "#/components/responses/Error"}),
+		}},
+		{method: "GET", path: "/v1/spread/:from/:to/:code", handle: SpreadHandler, doc: operation{
+			Summary:     "Spread between buy and sell rates in the period",
+			Description: "Spread of every currency in the tables C published in the period of up to 366 days, with the lowest, the highest and the average spread percentage.",
+			Tags:        []string{"rates"},
+			Parameters:  []parameter{fromParam, toParam, codeParam},
+			Responses:   jsend(arrayOf(ref("SpreadSeries"))),
+		}},
+		{method: "GET", path: "/v1/average/monthly/:month/:code", handle: MonthlyAverageHandler, doc: operation{
+			Summary:     "Mean of the average rates in the month",
+			Description: "Arithmetic mean of the rates from all tables A published in the month, until today in the current month.",
+			Tags:        []string{"rates"},
+			Parameters: []parameter{
+				{Name: "month", In: "path", Required: true, Schema: &schema{Type: "string", Pattern: `^\d{4}-\d{2}$`, Example: "2015-11"}},
+				codeParam,
+			},
+			Responses: jsend(ref("PeriodAverage")),
+		}},
+		{method: "GET", path: "/v1/average/yearly/:year/:code", handle: YearlyAverageHandler, doc: operation{
+			Summary:     "Mean of the average rates in the year",
+			Description: "Arithmetic mean of the rates from all tables A published in the year, until today in the current year.",
+			Tags:        []string{"rates"},
+			Parameters:  []parameter{yearParam, codeParam},
+			Responses:   jsend(ref("PeriodAverage")),
+		}},
+		{method: "GET", path: "/v1/tax-rate/:date/:code", alias: "/tax-rate/:date/:code", handle: TaxRateHandler, doc: operation{
+			Summary:     "Average rates for the invoice or transaction date",
+			Description: "Rates from the last table A published before the date, as Polish tax rules require, with the annotation for the invoice.",
+			Tags:        []string{"rates"},
+			Parameters:  []parameter{{Name: "date", In: "path", Required: true, Schema: dateInSchema, Description: "Date of the invoice or transaction"}, codeParam},
+			Responses:   jsend(ref("TaxRate")),
+		}},
+		{method: "POST", path: "/v1/batch", alias: "/batch", handle: BatchHandler, doc: operation{
+			Summary:     "Many currency tables at once",
+			Description: "Up to 1000 queries, in up to 1 MiB, resolved in the order given. Every result has its own JSend status. Every item counts as a request in the rate limit.",
+			Tags:        []string{"rates"},
+			RequestBody: jsonBody(arrayOf(ref("BatchItem"))),
+			Responses:   jsend(arrayOf(ref("BatchResult"))),
+		}},
+		{method: "GET", path: "/v1/gold/:date", alias: "/gold/:date", handle: GoldHandler, doc: operation{
+			Summary:    "Gold price",
+			Tags:       []string{"gold"},
+			Parameters: []parameter{dateParam},
+			Responses:  jsend(ref("GoldPrice")),
+		}},
+		{method: "GET", path: "/v1/gold/:date/:to", alias: "/gold/:date/:to", handle: GoldRangeHandler, doc: operation{
+			Summary:     "Gold prices published in the period",
+			Description: "The period can be up to 366 days long. The prices are streamed as they're fetched. With format=csv, or Accept: text/csv, there is a row per price.",
+			Tags:        []string{"gold"},
+			Parameters: []parameter{{Name: "date", In: "path", Required: true, Schema: dateInSchema, Description: "First day of the period"}, toParam,
+				{Name: "format", In: "query", Schema: &schema{Type: "string", Enum: []string{"json", "csv"}}}},
+			Responses: seriesResponses(ref("GoldPrice"), "date,price"),
+		}},
+		{method: "GET", path: "/v1/calendar/:year", alias: "/calendar/:year", handle: CalendarHandler, doc: operation{
+			Summary:     "Publication calendar",
+			Description: "Public holidays of the year and the dates the published tables don't match the calendar.",
+			Tags:        []string{"calendar"},
+			Parameters:  []parameter{yearParam},
+			Responses:   jsend(ref("Calendar")),
+		}},
+		{method: "GET", path: "/v1/graphql", alias: "/graphql", handle: GraphQLHandler, doc: graphQLDoc([]parameter{
+			{Name: "query", In: "query", Required: true, Schema: stringSchema},
+			{Name: "variables", In: "query", Schema: stringSchema, Description: "JSON object"},
+			{Name: "operationName", In: "query", Schema: stringSchema},
+		}, nil)},
+		{method: "POST", path: "/v1/graphql", alias: "/graphql", handle: GraphQLHandler, doc: graphQLDoc(nil, jsonBody(ref("GraphQLRequest")))},
+		{method: "GET", path: "/v1/openapi.json", alias: "/openapi.json", handle: OpenAPIHandler, public: true, doc: operation{
+			Summary: "This document",
+			Responses: map[string]*response{
+				"200": {Description: "OpenAPI 3 document", Content: map[string]mediaType{"application/json": {Schema: &schema{Type: "object"}}}},
+			},
+		}},
+	}
+
+	if publications != nil {
+		rs = append(rs, route{method: "GET", path: "/v1/stream/:type/:code", alias: "/stream/:type/:code", handle: StreamHandler, doc: operation{
+			Summary:     "Stream of new tables",
+			Description: "Server-Sent Events stream sending a table event whenever a new table is published. Send Last-Event-ID header to get the tables published since the given one.",
+			Tags:        []string{"rates"},
+			Parameters:  []parameter{typeParam, codeParam},
+			Responses: withResponse(jsend(nil), "200", &response{
+				Description: "Events with the tables as data and the table numbers as ids",
+				Content:     map[string]mediaType{"text/event-stream": {Schema: stringSchema}},
+			}),
+		}})
+	}
+
+	// The webhooks make the server send requests to the given URLs, so they can't be managed without the admin token
+	if webhooks != nil && adminToken != "" {
+		rs = append(rs,
+			route{method: "GET", path: "/v1/webhooks", alias: "/webhooks", handle: ListWebhooksHandler, admin: true, doc: operation{
+				Summary:   "Registered webhooks",
+				Tags:      []string{"webhooks"},
+				Responses: jsend(arrayOf(ref("Webhook"))),
+			}},
+			route{method: "POST", path: "/v1/webhooks", alias: "/webhooks", handle: CreateWebhookHandler, admin: true, doc: operation{
+				Summary:     "Register webhook",
+				Tags:        []string{"webhooks"},
+				RequestBody: jsonBody(ref("Webhook")),
+				Responses:   jsend(ref("Webhook")),
+			}},
+			route{method: "GET", path: "/v1/webhooks/:id", alias: "/webhooks/:id", handle: WebhookHandler, admin: true, doc: operation{
+				Summary:    "Webhook",
+				Tags:       []string{"webhooks"},
+				Parameters: []parameter{idParam},
+				Responses:  jsend(ref("Webhook")),
+			}},
+			route{method: "PUT", path: "/v1/webhooks/:id", alias: "/webhooks/:id", handle: UpdateWebhookHandler, admin: true, doc: operation{
+				Summary:     "Replace webhook",
+				Tags:        []string{"webhooks"},
+				Parameters:  []parameter{idParam},
+				RequestBody: jsonBody(ref("Webhook")),
+				Responses:   jsend(ref("Webhook")),
+			}},
+			route{method: "DELETE", path: "/v1/webhooks/:id", alias: "/webhooks/:id", handle: DeleteWebhookHandler, admin: true, doc: operation{
+				Summary:    "Remove webhook",
+				Tags:       []string{"webhooks"},
+				Parameters: []parameter{idParam},
+				Responses:  jsend(nil),
+			}},
+		)
+	}
+	return rs
+}
+
+func graphQLDoc(params []parameter, body *requestBody) operation {
+	return operation{
+		Summary:     "GraphQL query",
+		Description: "The response is {data, errors}, as GraphQL clients expect, and not JSend. Every root field of the query counts as a request in the rate limit.",
+		Tags:        []string{"graphql"},
+		Parameters:  params,
+		RequestBody: body,
+		Responses: map[string]*response{
+			"200": {Description: "Result of the query", Content: map[string]mediaType{"application/json": {Schema: ref("GraphQLResponse")}}},
+			"400": {Description: "The query couldn't be executed", Content: map[string]mediaType{"application/json": {Schema: ref("GraphQLResponse")}}},
+		},
+	}
+}
+
+// deprecated marks the responses of the alias as deprecated (RFC 9745), gives the date it will be removed on (RFC 8594)
+// and links the same resource under the versioned path.
+func deprecated(f handle, rt route) handle {
+	prefix := strings.TrimSuffix(rt.path, rt.alias)
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10))
+		w.Header().Set("Sunset", sunset.Format(http.TimeFormat))
+		w.Header().Set("Link", "<"+prefix+r.URL.RequestURI()+">; rel=\"successor-version\"")
+		return f(w, r, p)
+	}
+}
+
+// seriesResponses are JSend responses of the streamed series, like /range, with CSV as an alternative.
+func seriesResponses(item *schema, csvHeader string) map[string]*response {
+	rs := jsend(arrayOf(item))
+	ok := *rs["200"]
+	ok.Content = map[string]mediaType{
+		"application/json": ok.Content["application/json"],
+		"text/csv":         {Schema: &schema{Type: "string", Example: csvHeader}},
+	}
+	rs["200"] = &ok
+	return rs
+}
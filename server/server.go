@@ -2,90 +2,208 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/karolgorecki/nbp/limit"
+	"github.com/karolgorecki/nbp/poller"
 	"github.com/karolgorecki/nbp/svc"
+	"github.com/karolgorecki/nbp/webhook"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// Config holds what the handlers need to serve the requests.
+type Config struct {
+	// Backend is used to fetch the currency tables.
+	Backend svc.Backend
+	// Gold is used to fetch the gold prices. NBP Web API is used when it's not set.
+	Gold svc.GoldBackend
+	// Webhooks enables /webhooks routes when set, together with AdminToken.
+	Webhooks *webhook.Registry
+	// Publications enables /stream routes when set.
+	Publications *poller.Poller
+	// AdminToken is required as bearer token by the routes changing the configuration. They're disabled without it.
+	AdminToken string
+	// APIKeys are the keys of the clients with their rate limits. A request with another key is rejected.
+	APIKeys map[string]limit.Rate
+	// RequireAPIKey rejects the requests without the key, except the public routes.
+	RequireAPIKey bool
+	// IPRate limits the requests without the key per client IP.
+	IPRate limit.Rate
+	// TrustProxy takes the client IP from X-Forwarded-For header.
+	TrustProxy bool
+	// CORS is the policy of the cross-origin requests.
+	CORS CORS
+}
+
+// backend is used by the handlers to fetch the currency tables.
+var backend svc.Backend = svc.XMLBackend{}
+
+// gold is used by the handlers to fetch the gold prices.
+var gold svc.GoldBackend = svc.APIGoldBackend{}
+
+// webhooks keeps the registered webhooks.
+var webhooks *webhook.Registry
+
+// publications notifies about newly published tables.
+var publications *poller.Poller
+
+// adminToken is required by the routes changing the configuration.
+var adminToken string
+
+// apiKeys are the keys of the clients with their rate limits.
+var apiKeys map[string]limit.Rate
+
+// requireKey rejects the requests without the key.
+var requireKey bool
+
+// ipRate limits the requests without the key per client IP.
+var ipRate limit.Rate
+
+// trustProxy takes the client IP from X-Forwarded-For header.
+var trustProxy bool
+
+// registered are the routes registered by RegisterHandlers.
+var registered []route
+
 // RegisterHandlers does something
-func RegisterHandlers() *httprouter.Router {
+func RegisterHandlers(c Config) http.Handler {
+	backend = c.Backend
+	gold = c.Gold
+	if gold == nil {
+		gold = svc.APIGoldBackend{}
+	}
+	webhooks = c.Webhooks
+	publications = c.Publications
+	adminToken = c.AdminToken
+	apiKeys = c.APIKeys
+	requireKey = c.RequireAPIKey
+	ipRate = c.IPRate
+	trustProxy = c.TrustProxy
+
+	// The legacy route consumes the whole path space, so it gets its own router
+	// which is used when none of the other routes match.
+	legacy := httprouter.New()
+	legacy.NotFound = ntHandler{}
+
 	rt := httprouter.New()
-	rt.GET("/:date/:type/:code", errorHandler(IndexHandler))
+	registered = routes()
+	for _, r := range registered {
+		h := r.handle
+		if r.admin {
+			h = adminOnly(h)
+		}
+		h = limited(h, r.public)
+		rt.Handle(r.method, r.path, errorHandler(h))
 
-	rt.NotFound = ntHandler{}
+		if r.alias == "" {
+			continue
+		}
+		if r.legacy {
+			legacy.Handle(r.method, r.alias, errorHandler(deprecated(h, r)))
+		} else {
+			rt.Handle(r.method, r.alias, errorHandler(deprecated(h, r)))
+		}
+	}
+
+	rt.NotFound = legacy
 
 	fmt.Println("Running on: http://localhost:" + os.Getenv("PORT"))
-	return rt
+	return corsHandler{CORS: c.CORS, next: compressHandler{next: rt}}
 }
 
 // IndexHandler Does something
 func IndexHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
-	rDate := p.ByName("date")
-	rType := p.ByName("type")
-	rCode := p.ByName("code")
+	var res table
+	var err error
+	if base := strings.ToUpper(r.URL.Query().Get("base")); base != "" {
+		res, err = baseRates(p.ByName("date"), p.ByName("type"), p.ByName("code"), base)
+	} else {
+		res, err = rates(p.ByName("date"), p.ByName("type"), p.ByName("code"))
+	}
+	if err != nil {
+		return err
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
 
+// table is the currency table returned by the routes.
+// Date is the requested date, normalized to YYYY-MM-DD, e.g. when "yesterday" was requested.
+// Notice explains why the table from another date is returned, e.g. "No table published on 2015-12-25: holiday (Boże Narodzenie)".
+// Base is the currency the rates are expressed in, when it's not PLN.
+type table struct {
+	Date string `json:"date,omitempty"`
+	svc.Query
+	Notice string `json:"notice,omitempty"`
+	Base   string `json:"base,omitempty"`
+}
+
+// rates returns the table of given type for the date given in the request, filtered by code.
+func rates(rDate string, rType string, rCode string) (table, error) {
 	// Is the given date OK?
-	date, err := time.Parse("2006-01-02", rDate)
+	date, err := parseDate(rDate, svc.MinDate)
 	if err != nil {
-		handleOutput(w, http.StatusBadRequest, "Given date is wrong. Use 'YYYY-MM-DD'")
-		return nil
+		return table{}, err
 	}
 
 	// Is the type OK?
 	if rType != "avg" && rType != "both" {
-		handleOutput(w, http.StatusBadRequest, "Given type is wrong. Use 'avg' or 'both'")
-		return nil
+		return table{}, badRequest{errors.New("Given type is wrong. Use 'avg' or 'both'")}
 	}
 
-	// Disable future date
-	sDate := date.Format("2006-01-02")
-	if time.Now().Before(date) {
-		handleOutput(w, http.StatusBadRequest, "Given date is wrong. Can't use future date")
-		return nil
+	t, err := lastTable(date, rType, rCode)
+	if err != nil {
+		return table{}, err
 	}
+	if err := notYetPublished(date, rType, t); err != nil {
+		return table{}, err
+	}
+	t.Date = date.Format("2006-01-02")
+	return t, nil
+}
 
-	// Disable date before 2002-01-02 -> first record in NBP
-	minDate, _ := time.Parse("2006-01-02", "2002-01-02")
-	if date.Before(minDate) {
-		handleOutput(w, http.StatusBadRequest, "Given date is wrong. Min date is 2002-01-02")
+// notYetPublished returns the error when the table of today was asked for, but NBP hasn't published it yet,
+// so t is the latest table of the type published before.
+func notYetPublished(date time.Time, rType string, t table) error {
+	if !svc.NotYetPublished(date, t.Query) {
 		return nil
 	}
+	err := svc.NotYetPublishedError{Date: date, Type: rType, Latest: t.Query}
+	return notPublished{err, err.RetryAfter()}
+}
 
-	// Get the file containing the the currency data
-	f, err := svc.GetResourceLocation(sDate, rType)
+// lastTable returns the table of given type published on the date or the last one before it.
+func lastTable(date time.Time, rType string, rCode string) (table, error) {
+	res, err := svc.LastTable(backend, date, rType, rCode)
+	if err == svc.ErrNotPublished {
+		return table{}, badRequest{errors.New("Resource for given date was not found")}
+	}
 	if err != nil {
-		handleOutput(w, http.StatusBadRequest, "There was some problem with your request")
-		return nil
+		return table{}, badRequest{errors.New("There was some problem with your request")}
 	}
 
-	// When the currency rate was not found for given date try to go back one day.
-	// It's used to get currencies for holidays, or weekends
-	prevData := date
-	for f == "" {
-		prevData = prevData.AddDate(0, 0, -1)
-		f, err = svc.GetResourceLocation(prevData.Format("2006-01-02"), rType)
-		if err != nil {
-			log.Fatalln(err)
-		}
+	t := table{Query: res, Notice: svc.Notice(date, res)}
+	if rType == "both" {
+		t.Query = svc.Spreads(res)
 	}
-	if f == "" {
-		handleOutput(w, http.StatusBadRequest, "Resource for given date was not found")
-		return nil
-	}
-	res, err := svc.GetData(f, rCode)
+	return t, nil
+}
+
+// parseDate parses the date given in the request, see svc.ParseDate.
+func parseDate(s string, min time.Time) (time.Time, error) {
+	date, err := svc.ParseDate(s, min)
 	if err != nil {
-		handleOutput(w, http.StatusBadRequest, "There was some problem with your request")
-		return nil
+		return date, badRequest{err}
 	}
-
-	handleOutput(w, http.StatusOK, res)
-	return nil
+	return date, nil
 }
 
 // handleOutput handles the response for each endpoint.
@@ -93,7 +211,6 @@ func IndexHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) e
 // See https://labs.omniti.com/labs/jsend
 func handleOutput(w http.ResponseWriter, code int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(code)
 
 	success := false
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// spreadPoint is the spread of the currency in a single table C.
+type spreadPoint struct {
+	Date          string `json:"date"`
+	TableNumber   string `json:"tableNumber"`
+	Buy           string `json:"buy"`
+	Sell          string `json:"sell"`
+	Mid           string `json:"mid"`
+	Spread        string `json:"spread"`
+	SpreadPercent string `json:"spreadPercent"`
+}
+
+// spreadSeries is the spread of the currency in the tables published in the period,
+// with the lowest, the highest and the average spread percentage.
+type spreadSeries struct {
+	Code           string        `json:"code"`
+	Name           string        `json:"name"`
+	Points         []spreadPoint `json:"points"`
+	MinPercent     string        `json:"minPercent"`
+	MaxPercent     string        `json:"maxPercent"`
+	AveragePercent string        `json:"averagePercent"`
+}
+
+// SpreadHandler returns how the spread between the buy and sell rates from table C changed in the period.
+func SpreadHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	from, err := parseDate(p.ByName("from"), svc.MinDate)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(p.ByName("to"), svc.MinDate)
+	if err != nil {
+		return err
+	}
+	if err := svc.CheckPeriod(from, to); err != nil {
+		return badRequest{err}
+	}
+
+	var codes []string
+	series := map[string]*spreadSeries{}
+	percents := map[string][]*big.Rat{}
+
+	err = svc.EachTable(backend, from, to, "both", p.ByName("code"), func(q svc.Query) error {
+		for _, c := range svc.Spreads(q).Currencies {
+			// The exact percentage is used for the statistics, not the rounded one
+			_, _, percent, err := svc.CurrencySpread(c.Buy, c.Sell)
+			if err != nil {
+				continue
+			}
+
+			s, ok := series[c.Code]
+			if !ok {
+				s = &spreadSeries{Code: c.Code, Name: c.Name, Points: []spreadPoint{}}
+				series[c.Code] = s
+				codes = append(codes, c.Code)
+			}
+			s.Points = append(s.Points, spreadPoint{
+				Date:          q.FromData,
+				TableNumber:   q.TableNumber,
+				Buy:           c.Buy,
+				Sell:          c.Sell,
+				Mid:           c.Mid,
+				Spread:        c.Spread,
+				SpreadPercent: c.SpreadPercent,
+			})
+			percents[c.Code] = append(percents[c.Code], percent)
+		}
+		return nil
+	})
+	if err != nil {
+		return badRequest{errors.New("There was some problem with your request")}
+	}
+
+	res := []spreadSeries{}
+	for _, code := range codes {
+		s := series[code]
+		min, max, sum := percents[code][0], percents[code][0], new(big.Rat)
+		for _, pc := range percents[code] {
+			if pc.Cmp(min) < 0 {
+				min = pc
+			}
+			if pc.Cmp(max) > 0 {
+				max = pc
+			}
+			sum.Add(sum, pc)
+		}
+		avg := sum.Quo(sum, big.NewRat(int64(len(percents[code])), 1))
+
+		s.MinPercent = svc.FormatDecimal(min, svc.PercentPlaces)
+		s.MaxPercent = svc.FormatDecimal(max, svc.PercentPlaces)
+		s.AveragePercent = svc.FormatDecimal(avg, svc.PercentPlaces)
+		res = append(res, *s)
+	}
+
+	handleOutput(w, http.StatusOK, res)
+	return nil
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestSpreadHandler(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		name     string
+		from, to string
+		code     string
+		status   int
+		want     string
+	}{
+		{"period", "2015-11-24", "2015-11-25", "USD", http.StatusOK,
+			`"minPercent":"2,0005","maxPercent":"2,0010","averagePercent":"2,0008"`},
+		{"points", "2015-11-24", "2015-11-25", "USD", http.StatusOK,
+			`{"date":"2015-11-25","tableNumber":"229/C/NBP/2015","buy":"3,7907","sell":"3,8673","mid":"3,82900","spread":"0,0766","spreadPercent":"2,0005"}`},
+		{"no tables", "2015-11-28", "2015-11-29", "USD", http.StatusOK, `"data":[]`},
+		{"too long", "2015-01-01", "2016-01-02", "USD", http.StatusBadRequest, "The period can't be longer than 366 days"},
+		{"reversed", "2015-11-25", "2015-11-24", "USD", http.StatusBadRequest, "The end date can't be before the start date"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(SpreadHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{
+			{Key: "from", Value: tt.from}, {Key: "to", Value: tt.to}, {Key: "code", Value: tt.code}})
+
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: %d %s, want %d with %s", tt.name, w.Code, w.Body, tt.status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/karolgorecki/nbp/poller"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// heartbeat is the interval of the comments keeping the idle stream open.
+const heartbeat = 30 * time.Second
+
+// StreamHandler keeps the Server-Sent Events stream open and sends an event whenever a new table of given type
+// containing any of given currencies is published. The event id is the table number, so a client reconnecting
+// with Last-Event-ID header gets the tables published in the meantime.
+func StreamHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	rType := p.ByName("type")
+	if rType != "avg" && rType != "both" {
+		return badRequest{errors.New("Given type is wrong. Use 'avg' or 'both'")}
+	}
+	rCode := p.ByName("code")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported by the response writer")
+	}
+
+	pubs, cancel := publications.Subscribe()
+	defer cancel()
+
+	// The tables published since the last event the client got
+	var backlog []poller.Publication
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		backlog = publications.Since(id)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 10000\n\n")
+	flusher.Flush()
+
+	sent := map[string]bool{}
+	send := func(pub poller.Publication) error {
+		if pub.Type != rType || sent[pub.Table.TableNumber] {
+			return nil
+		}
+		sent[pub.Table.TableNumber] = true
+
+		q := svc.FilterCurrencies(pub.Table, rCode)
+		if rType == "both" {
+			q = svc.Spreads(q)
+		}
+		if len(q.Currencies) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: table\ndata: %s\n\n", q.TableNumber, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, pub := range backlog {
+		if err := send(pub); err != nil {
+			return nil
+		}
+	}
+
+	tick := time.NewTicker(heartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case pub, ok := <-pubs:
+			if !ok {
+				return nil
+			}
+			if err := send(pub); err != nil {
+				return nil
+			}
+		case <-tick.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/poller"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// fixtureEntries returns the index entries of the fixture tables A and C published on the dates.
+func fixtureEntries(dates ...string) []svc.Entry {
+	var res []svc.Entry
+	for _, d := range dates {
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			panic(err)
+		}
+		for _, letter := range []string{"a", "c"} {
+			res = append(res, svc.Entry{File: letter + date.Format("060102"), Type: letter, Date: date})
+		}
+	}
+	return res
+}
+
+func TestStreamHandlerResumes(t *testing.T) {
+	defer func(p *poller.Poller) { publications = p }(publications)
+
+	// The tables of 2015-11-24 are known at the first check, the later ones are published
+	var index []svc.Entry
+	publications = poller.New(time.Minute, stubBackend{})
+	publications.Index = func(year int) ([]svc.Entry, error) { return index, nil }
+	for _, dates := range [][]string{{"2015-11-24"}, {"2015-11-24", "2015-11-25", "2015-11-27", "2015-11-30"}} {
+		index = fixtureEntries(dates...)
+		if err := publications.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		rType       string
+		want        []string
+	}{
+		{"new client", "", "avg", nil},
+		{"missed", "229/A/NBP/2015", "avg", []string{"230/A/NBP/2015", "231/A/NBP/2015"}},
+		{"missed after other type", "229/A/NBP/2015", "both", []string{"229/C/NBP/2015", "230/C/NBP/2015", "231/C/NBP/2015"}},
+		{"none missed", "231/C/NBP/2015", "avg", nil},
+		// The event before the publications the server keeps
+		{"unknown", "228/A/NBP/2015", "avg", []string{"229/A/NBP/2015", "230/A/NBP/2015", "231/A/NBP/2015"}},
+	}
+
+	ids := regexp.MustCompile(`(?m)^id: (.*)$`)
+	for _, tt := range tests {
+		// The client is gone once the missed tables are sent, so the handler returns
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := httptest.NewRequest("GET", "/v1/stream/"+tt.rType+"/USD", nil).WithContext(ctx)
+		if tt.lastEventID != "" {
+			r.Header.Set("Last-Event-ID", tt.lastEventID)
+		}
+		w := httptest.NewRecorder()
+		errorHandler(StreamHandler)(w, r, httprouter.Params{{Key: "type", Value: tt.rType}, {Key: "code", Value: "USD"}})
+
+		var got []string
+		for _, m := range ids.FindAllStringSubmatch(w.Body.String(), -1) {
+			got = append(got, m[1])
+		}
+		if w.Code != http.StatusOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %d with events %v, want %v", tt.name, w.Code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// taxRate is the rate used to convert the amounts of invoices and transactions for VAT and CIT.
+type taxRate struct {
+	Date string `json:"date"`
+	table
+	Annotation string `json:"annotation"`
+}
+
+// TaxRateHandler returns the average rates from the last table A published before the given date.
+// Polish tax rules require the rate from the last business day preceding the invoice or transaction date,
+// so unlike IndexHandler the table published on the date itself is never used.
+func TaxRateHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	date, err := parseDate(p.ByName("date"), svc.MinDate.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	q, err := lastTable(date.AddDate(0, 0, -1), "avg", p.ByName("code"))
+	if err != nil {
+		return err
+	}
+	q.Notice = taxNotice(date, q.Query)
+
+	handleOutput(w, http.StatusOK, taxRate{
+		Date:       date.Format("2006-01-02"),
+		table:      q,
+		Annotation: annotation(q.Query),
+	})
+	return nil
+}
+
+// taxNotice explains why q, the table used for the date, is not from the day before it, e.g.
+// "The last table before 2015-11-30 was published on 2015-11-27. No table published on 2015-11-28: weekend, 2015-11-29: weekend".
+// It's empty when q is from the day before.
+func taxNotice(date time.Time, q svc.Query) string {
+	from, err := time.Parse("2006-01-02", q.FromData)
+	if err != nil || !from.Before(date.AddDate(0, 0, -1)) {
+		return ""
+	}
+
+	var days []string
+	for d := from.AddDate(0, 0, 1); d.Before(date); d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		if reason := calendar.Reason(d); reason != "" {
+			day += ": " + reason
+		}
+		days = append(days, day)
+	}
+	return "The last table before " + date.Format("2006-01-02") + " was published on " + q.FromData +
+		". No table published on " + strings.Join(days, ", ")
+}
+
+// annotation formats the table number and date the way they're cited on invoices,
+// e.g. "Kurs średni NBP z tabeli nr 229/A/NBP/2015 z dnia 25.11.2015".
+func annotation(q svc.Query) string {
+	date := q.FromData
+	if d, err := time.Parse("2006-01-02", q.FromData); err == nil {
+		date = d.Format("02.01.2006")
+	}
+	return "Kurs średni NBP z tabeli nr " + q.TableNumber + " z dnia " + date
+}
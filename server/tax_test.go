@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestTaxRateHandler(t *testing.T) {
+	defer withStub()()
+
+	tests := []struct {
+		date string
+		// table is the number of the table used, empty when the request fails
+		table  string
+		notice string
+	}{
+		{"2015-11-25", "228/A/NBP/2015", ""},
+		// The table of the date itself is never used
+		{"2015-11-10", "216/A/NBP/2015", ""},
+		// Weekends
+		{"2015-11-30", "230/A/NBP/2015",
+			"The last table before 2015-11-30 was published on 2015-11-27. No table published on 2015-11-28: weekend, 2015-11-29: weekend"},
+		{"2015-11-29", "230/A/NBP/2015", "The last table before 2015-11-29 was published on 2015-11-27. No table published on 2015-11-28: weekend"},
+		{"2015-11-28", "230/A/NBP/2015", ""},
+		// Holidays
+		{"2015-11-12", "217/A/NBP/2015",
+			"The last table before 2015-11-12 was published on 2015-11-10. No table published on 2015-11-11: holiday (Narodowe Święto Niepodległości)"},
+		// NBP didn't publish on a business day
+		{"2015-11-27", "229/A/NBP/2015", "The last table before 2015-11-27 was published on 2015-11-25. No table published on 2015-11-26"},
+		{"2015-13-01", "", ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		errorHandler(TaxRateHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{{Key: "date", Value: tt.date}, {Key: "code", Value: "USD"}})
+
+		if tt.table == "" {
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: %d %s, want %d", tt.date, w.Code, w.Body, http.StatusBadRequest)
+			}
+			continue
+		}
+		var res struct {
+			Data struct {
+				Date        string
+				TableNumber string
+				Notice      string
+				Annotation  string
+				Currencies  []struct{ Code, Average string }
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK {
+			t.Errorf("%s: %d %s", tt.date, w.Code, w.Body)
+			continue
+		}
+		if d := res.Data; d.Date != tt.date || d.TableNumber != tt.table || d.Notice != tt.notice || len(d.Currencies) != 1 {
+			t.Errorf("%s: %+v, want table %s with notice %q", tt.date, d, tt.table, tt.notice)
+		}
+	}
+}
+
+func TestTaxRateAnnotation(t *testing.T) {
+	defer withStub()()
+
+	w := httptest.NewRecorder()
+	errorHandler(TaxRateHandler)(w, httptest.NewRequest("GET", "/", nil), httprouter.Params{{Key: "date", Value: "2015-11-30"}, {Key: "code", Value: "EUR"}})
+
+	var res struct {
+		Data struct {
+			Annotation string
+			Currencies []struct{ Code, Average string }
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err, w.Body)
+	}
+	if want := "Kurs średni NBP z tabeli nr 230/A/NBP/2015 z dnia 27.11.2015"; res.Data.Annotation != want {
+		t.Errorf("annotation = %q, want %q", res.Data.Annotation, want)
+	}
+	if c := res.Data.Currencies; len(c) != 1 || c[0].Code != "EUR" || c[0].Average != "4,2640" {
+		t.Errorf("currencies = %+v, want EUR at 4,2640", c)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/karolgorecki/nbp/webhook"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type handle func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error
+
+// adminOnly requires the admin token as the bearer token. Nobody is allowed when the token isn't configured.
+func adminOnly(f handle) handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+		given := []byte(r.Header.Get("Authorization"))
+		if adminToken == "" || subtle.ConstantTimeCompare(given, []byte("Bearer "+adminToken)) != 1 {
+			return unauthorized{errors.New("Given token is wrong. Use 'Authorization: Bearer <token>' header")}
+		}
+		return f(w, r, p)
+	}
+}
+
+// ListWebhooksHandler returns the registered webhooks, without their secrets.
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	hooks := webhooks.List()
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	handleOutput(w, http.StatusOK, hooks)
+	return nil
+}
+
+// CreateWebhookHandler registers the webhook given as JSON {url, secret, types}.
+// The secret is generated unless it's given. It's returned only by this handler.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	h, err := decodeWebhook(r)
+	if err != nil {
+		return err
+	}
+	if err := webhook.Validate(h); err != nil {
+		return badRequest{err}
+	}
+
+	h, err = webhooks.Add(h)
+	if err != nil {
+		return err
+	}
+
+	handleOutput(w, http.StatusOK, h)
+	return nil
+}
+
+// WebhookHandler returns the webhook with given id, without its secret.
+func WebhookHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	h, ok := webhooks.Get(p.ByName("id"))
+	if !ok {
+		return notFound{}
+	}
+	h.Secret = ""
+
+	handleOutput(w, http.StatusOK, h)
+	return nil
+}
+
+// UpdateWebhookHandler replaces the webhook with given id. The secret is kept unless a new one is given.
+func UpdateWebhookHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	h, err := decodeWebhook(r)
+	if err != nil {
+		return err
+	}
+	if err := webhook.Validate(h); err != nil {
+		return badRequest{err}
+	}
+
+	h, ok, err := webhooks.Update(p.ByName("id"), h)
+	if !ok {
+		return notFound{}
+	}
+	if err != nil {
+		return err
+	}
+	h.Secret = ""
+
+	handleOutput(w, http.StatusOK, h)
+	return nil
+}
+
+// DeleteWebhookHandler removes the webhook with given id.
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) error {
+	ok, err := webhooks.Delete(p.ByName("id"))
+	if !ok {
+		return notFound{}
+	}
+	if err != nil {
+		return err
+	}
+
+	handleOutput(w, http.StatusOK, nil)
+	return nil
+}
+
+func decodeWebhook(r *http.Request) (webhook.Hook, error) {
+	var h webhook.Hook
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		return h, badRequest{errors.New("Given webhook is wrong. Send JSON {url, secret, types}")}
+	}
+	return h, nil
+}
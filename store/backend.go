@@ -0,0 +1,105 @@
+package store
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// Backend reads the tables from the store and fetches the ones it doesn't know from another backend,
+// writing them through to the store.
+type Backend struct {
+	Store   *Store
+	Backend svc.Backend
+}
+
+// Table implements svc.Backend.
+func (b Backend) Table(date time.Time, sType string, code string) (svc.Query, error) {
+	if q, known, published := b.Store.Get(sType, date, code); known {
+		if !published {
+			return svc.Query{}, svc.ErrNotPublished
+		}
+		return q, nil
+	}
+
+	q, err := b.Backend.Table(date, sType, "*")
+	if err == svc.ErrNotPublished && final(date) {
+		b.putMissing(sType, date)
+	}
+	if err != nil {
+		return svc.Query{}, err
+	}
+
+	b.put(sType, date, q)
+	return svc.FilterCurrencies(q, code), nil
+}
+
+// Tables implements svc.Backend.
+// The dates the store doesn't know are fetched from the other backend in one go.
+func (b Backend) Tables(from time.Time, to time.Time, sType string, code string) ([]svc.Query, error) {
+	res, unknown := b.Store.Range(sType, from, to, code)
+	if len(unknown) == 0 {
+		return res, nil
+	}
+
+	tables, err := b.Backend.Tables(unknown[0], unknown[len(unknown)-1], sType, "*")
+	if err != nil {
+		return nil, err
+	}
+
+	writeThrough(unknown, len(tables), func(i int) string { return tables[i].FromData }, func(i int, date time.Time) {
+		b.put(sType, date, tables[i])
+		// The fetched tables are returned even if they couldn't be stored
+		res = append(res, svc.FilterCurrencies(tables[i], code))
+	}, func(date time.Time) {
+		b.putMissing(sType, date)
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].FromData < res[j].FromData })
+	return res, nil
+}
+
+// writeThrough stores the n elements fetched for the unknown dates, the ones the store didn't know.
+// date returns the publication date of the i-th element and put stores it, the elements of the other dates are skipped.
+// The unknown dates nothing was published on are stored with putMissing, once they're final.
+func writeThrough(unknown []time.Time, n int, date func(i int) string, put func(i int, date time.Time), putMissing func(date time.Time)) {
+	isUnknown := map[string]bool{}
+	for _, d := range unknown {
+		isUnknown[d.Format("2006-01-02")] = true
+	}
+	for i := 0; i < n; i++ {
+		d, err := time.Parse("2006-01-02", date(i))
+		if err != nil || !isUnknown[date(i)] {
+			continue
+		}
+		put(i, d)
+		delete(isUnknown, date(i))
+	}
+	for _, d := range unknown {
+		if isUnknown[d.Format("2006-01-02")] && final(d) {
+			putMissing(d)
+		}
+	}
+}
+
+// put writes the table to the store. The table is returned to the client even if it couldn't be stored.
+func (b Backend) put(sType string, date time.Time, q svc.Query) {
+	if err := b.Store.Put(sType, date, q); err != nil {
+		log.Println(err)
+	}
+}
+
+func (b Backend) putMissing(sType string, date time.Time) {
+	if err := b.Store.PutMissing(sType, date); err != nil {
+		log.Println(err)
+	}
+}
+
+// final reports whether it's known for sure that no table will be published on the date,
+// i.e. the date is in the past.
+func final(date time.Time) bool {
+	return date.Before(calendar.Today())
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// GoldBackend reads the gold prices from the store and fetches the ones it doesn't know from another backend,
+// writing them through to the store, the same as Backend does with the tables.
+type GoldBackend struct {
+	Store   *Store
+	Backend svc.GoldBackend
+}
+
+// GoldPrice implements svc.GoldBackend.
+func (b GoldBackend) GoldPrice(date time.Time) (svc.GoldPrice, error) {
+	if p, known, published := b.Store.GetGold(date); known {
+		if !published {
+			return svc.GoldPrice{}, svc.ErrNotPublished
+		}
+		return p, nil
+	}
+
+	p, err := b.Backend.GoldPrice(date)
+	if err == svc.ErrNotPublished && final(date) {
+		b.putMissing(date)
+	}
+	if err != nil {
+		return svc.GoldPrice{}, err
+	}
+
+	b.put(date, p)
+	return p, nil
+}
+
+// GoldPrices implements svc.GoldBackend.
+// The dates the store doesn't know are fetched from the other backend in one go.
+func (b GoldBackend) GoldPrices(from time.Time, to time.Time) ([]svc.GoldPrice, error) {
+	res, unknown := b.Store.GoldRange(from, to)
+	if len(unknown) == 0 {
+		return res, nil
+	}
+
+	prices, err := b.Backend.GoldPrices(unknown[0], unknown[len(unknown)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	writeThrough(unknown, len(prices), func(i int) string { return prices[i].Date }, func(i int, date time.Time) {
+		b.put(date, prices[i])
+		// The fetched prices are returned even if they couldn't be stored
+		res = append(res, prices[i])
+	}, b.putMissing)
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Date < res[j].Date })
+	return res, nil
+}
+
+// put writes the price to the store. The price is returned to the client even if it couldn't be stored.
+func (b GoldBackend) put(date time.Time, p svc.GoldPrice) {
+	if err := b.Store.PutGold(date, p); err != nil {
+		log.Println(err)
+	}
+}
+
+func (b GoldBackend) putMissing(date time.Time) {
+	if err := b.Store.PutGoldMissing(date); err != nil {
+		log.Println(err)
+	}
+}
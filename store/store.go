@@ -0,0 +1,191 @@
+// Package store persists the parsed currency tables in a local file,
+// so they don't have to be fetched from NBP again.
+//
+// The file holds one JSON record per line and is only appended to.
+// All records are loaded into memory when the store is opened.
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// key identifies the table by its type ("avg" or "both") and date.
+// The gold prices are kept under the "gold" type.
+type key struct {
+	sType string
+	date  string
+}
+
+// record is a line of the store file.
+// Missing records remember the dates no table was published on.
+type record struct {
+	Type    string         `json:"type"`
+	Date    string         `json:"date"`
+	Missing bool           `json:"missing,omitempty"`
+	Table   *svc.Query     `json:"table,omitempty"`
+	Gold    *svc.GoldPrice `json:"gold,omitempty"`
+}
+
+// goldType is the type of the records holding the gold prices.
+const goldType = "gold"
+
+// Store keeps the tables by type, date and currency code, and the gold prices by date.
+// It's safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	f       *os.File
+	tables  map[key]svc.Query
+	gold    map[string]svc.GoldPrice
+	missing map[key]bool
+}
+
+// Open opens the store file, creating it if it doesn't exist, and loads all tables.
+func Open(path string) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{f: f, tables: map[key]svc.Query{}, gold: map[string]svc.GoldPrice{}, missing: map[key]bool{}}
+
+	scn := bufio.NewScanner(f)
+	scn.Buffer(nil, 1<<20)
+	for scn.Scan() {
+		var r record
+		// Skip the line left incomplete when the server was stopped while writing it
+		if err := json.Unmarshal(scn.Bytes(), &r); err != nil {
+			continue
+		}
+		s.load(r)
+	}
+	if err := scn.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load(r record) {
+	k := key{r.Type, r.Date}
+	if r.Missing {
+		s.missing[k] = true
+	} else if r.Table != nil {
+		// The tables stored before the rates were given per unit are converted
+		s.tables[k] = svc.PerUnit(*r.Table)
+	} else if r.Gold != nil {
+		s.gold[r.Date] = *r.Gold
+	}
+}
+
+// Close closes the store file.
+func (s *Store) Close() error {
+	return s.f.Close()
+}
+
+// Put stores the whole table of given type published on the date.
+func (s *Store) Put(sType string, date time.Time, q svc.Query) error {
+	return s.append(record{Type: sType, Date: date.Format("2006-01-02"), Table: &q})
+}
+
+// PutMissing stores that no table of given type was published on the date.
+func (s *Store) PutMissing(sType string, date time.Time) error {
+	return s.append(record{Type: sType, Date: date.Format("2006-01-02"), Missing: true})
+}
+
+// PutGold stores the gold price published on the date.
+func (s *Store) PutGold(date time.Time, p svc.GoldPrice) error {
+	return s.append(record{Type: goldType, Date: date.Format("2006-01-02"), Gold: &p})
+}
+
+// PutGoldMissing stores that no gold price was published on the date.
+func (s *Store) PutGoldMissing(date time.Time) error {
+	return s.PutMissing(goldType, date)
+}
+
+func (s *Store) append(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.load(r)
+	return nil
+}
+
+// Get returns the table of given type published on the date, filtered by code.
+// The second result tells if the store knows the date, the third one if a table was published on it.
+func (s *Store) Get(sType string, date time.Time, code string) (svc.Query, bool, bool) {
+	k := key{sType, date.Format("2006-01-02")}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if q, ok := s.tables[k]; ok {
+		return svc.FilterCurrencies(q, code), true, true
+	}
+	return svc.Query{}, s.missing[k], false
+}
+
+// Range returns the stored tables of given type published between from and to (inclusive), ordered by date.
+// The dates the store doesn't know are returned too, so the caller can fetch them.
+func (s *Store) Range(sType string, from time.Time, to time.Time, code string) ([]svc.Query, []time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []svc.Query{}
+	var unknown []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		k := key{sType, d.Format("2006-01-02")}
+		if q, ok := s.tables[k]; ok {
+			res = append(res, svc.FilterCurrencies(q, code))
+		} else if !s.missing[k] {
+			unknown = append(unknown, d)
+		}
+	}
+	return res, unknown
+}
+
+// GetGold returns the gold price published on the date.
+// The second result tells if the store knows the date, the third one if a price was published on it.
+func (s *Store) GetGold(date time.Time) (svc.GoldPrice, bool, bool) {
+	d := date.Format("2006-01-02")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p, ok := s.gold[d]; ok {
+		return p, true, true
+	}
+	return svc.GoldPrice{}, s.missing[key{goldType, d}], false
+}
+
+// GoldRange returns the stored gold prices published between from and to (inclusive), ordered by date.
+// The dates the store doesn't know are returned too, so the caller can fetch them.
+func (s *Store) GoldRange(from time.Time, to time.Time) ([]svc.GoldPrice, []time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []svc.GoldPrice{}
+	var unknown []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		k := d.Format("2006-01-02")
+		if p, ok := s.gold[k]; ok {
+			res = append(res, p)
+		} else if !s.missing[key{goldType, k}] {
+			unknown = append(unknown, d)
+		}
+	}
+	return res, unknown
+}
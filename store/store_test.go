@@ -0,0 +1,269 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/karolgorecki/nbp/svc"
+)
+
+// stubBackend serves the tables by date and counts the calls.
+type stubBackend struct {
+	tables map[string]svc.Query
+	calls  int
+}
+
+func (b *stubBackend) Table(date time.Time, sType string, code string) (svc.Query, error) {
+	b.calls++
+	q, ok := b.tables[date.Format("2006-01-02")]
+	if !ok {
+		return svc.Query{}, svc.ErrNotPublished
+	}
+	return svc.FilterCurrencies(q, code), nil
+}
+
+func (b *stubBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]svc.Query, error) {
+	b.calls++
+	res := []svc.Query{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if q, ok := b.tables[d.Format("2006-01-02")]; ok {
+			res = append(res, svc.FilterCurrencies(q, code))
+		}
+	}
+	return res, nil
+}
+
+// stubGoldBackend serves the gold prices by date and counts the calls.
+type stubGoldBackend struct {
+	prices map[string]svc.GoldPrice
+	calls  int
+}
+
+func (b *stubGoldBackend) GoldPrice(date time.Time) (svc.GoldPrice, error) {
+	b.calls++
+	p, ok := b.prices[date.Format("2006-01-02")]
+	if !ok {
+		return svc.GoldPrice{}, svc.ErrNotPublished
+	}
+	return p, nil
+}
+
+func (b *stubGoldBackend) GoldPrices(from time.Time, to time.Time) ([]svc.GoldPrice, error) {
+	b.calls++
+	res := []svc.GoldPrice{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if p, ok := b.prices[d.Format("2006-01-02")]; ok {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// table returns the table A published on the date with USD and EUR rates.
+func table(t *testing.T, date string) svc.Query {
+	var q svc.Query
+	err := json.Unmarshal([]byte(`{"fromDate":"`+date+`","tableNumber":"228/A/NBP/2015","currencies":[
+		{"code":"USD","name":"dolar amerykański","ratio":"1","average":"3,8280"},
+		{"code":"EUR","name":"euro","ratio":"1","average":"4,2535"}]}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// openStore opens the store in a new file, closed when the test ends.
+func openStore(t *testing.T) (*Store, string) {
+	path := filepath.Join(t.TempDir(), "store.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func TestStoreReload(t *testing.T) {
+	s, path := openStore(t)
+	q := table(t, "2015-11-24")
+	gold := svc.GoldPrice{Date: "2015-11-24", Price: "128.97"}
+
+	if err := s.Put("avg", date("2015-11-24"), q); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutMissing("avg", date("2015-11-22")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutGold(date("2015-11-24"), gold); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutGoldMissing(date("2015-11-22")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 4 {
+		t.Errorf("%d lines in the store file, want 4:\n%s", n, data)
+	}
+	// The line left incomplete by a stopped server is skipped
+	if err := os.WriteFile(path, append(data, `{"type":"avg","date":"2015-11-25","tab`...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got, known, published := s.Get("avg", date("2015-11-24"), "USD"); !known || !published || !reflect.DeepEqual(got, svc.FilterCurrencies(q, "USD")) {
+		t.Errorf("Get(2015-11-24) = %+v, %v, %v", got, known, published)
+	}
+	if _, known, published := s.Get("avg", date("2015-11-22"), "*"); !known || published {
+		t.Errorf("Get(2015-11-22) = %v, %v, want known and not published", known, published)
+	}
+	if _, known, _ := s.Get("avg", date("2015-11-25"), "*"); known {
+		t.Error("Get(2015-11-25) is known from the incomplete line")
+	}
+	if _, known, _ := s.Get("both", date("2015-11-24"), "*"); known {
+		t.Error("Get(both, 2015-11-24) is known from the avg table")
+	}
+	if got, known, published := s.GetGold(date("2015-11-24")); !known || !published || got != gold {
+		t.Errorf("GetGold(2015-11-24) = %+v, %v, %v", got, known, published)
+	}
+	if _, known, published := s.GetGold(date("2015-11-22")); !known || published {
+		t.Errorf("GetGold(2015-11-22) = %v, %v, want known and not published", known, published)
+	}
+
+	res, unknown := s.Range("avg", date("2015-11-22"), date("2015-11-25"), "*")
+	if !reflect.DeepEqual(res, []svc.Query{q}) || !reflect.DeepEqual(unknown, []time.Time{date("2015-11-23"), date("2015-11-25")}) {
+		t.Errorf("Range = %+v, %v", res, unknown)
+	}
+}
+
+func TestBackend(t *testing.T) {
+	s, path := openStore(t)
+	tables := map[string]svc.Query{"2015-11-24": table(t, "2015-11-24"), "2015-11-25": table(t, "2015-11-25")}
+	stub := &stubBackend{tables: tables}
+	b := Backend{Store: s, Backend: stub}
+
+	// Fetched once, then served from the store
+	for i := 0; i < 2; i++ {
+		q, err := b.Table(date("2015-11-24"), "avg", "USD")
+		if err != nil || !reflect.DeepEqual(q, svc.FilterCurrencies(tables["2015-11-24"], "USD")) {
+			t.Errorf("Table(2015-11-24) = %+v, %v", q, err)
+		}
+		if _, err := b.Table(date("2015-11-22"), "avg", "USD"); err != svc.ErrNotPublished {
+			t.Errorf("Table(2015-11-22) error = %v, want ErrNotPublished", err)
+		}
+	}
+	if stub.calls != 2 {
+		t.Errorf("%d calls to the backend, want 2", stub.calls)
+	}
+
+	// Only the dates the store doesn't know are fetched
+	stub.calls = 0
+	for i := 0; i < 2; i++ {
+		res, err := b.Tables(date("2015-11-22"), date("2015-11-25"), "avg", "*")
+		if err != nil || !reflect.DeepEqual(res, []svc.Query{tables["2015-11-24"], tables["2015-11-25"]}) {
+			t.Errorf("Tables = %+v, %v", res, err)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("%d calls to the backend, want 1", stub.calls)
+	}
+
+	// The store file keeps what was fetched
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	res, unknown := s2.Range("avg", date("2015-11-22"), date("2015-11-25"), "*")
+	if len(res) != 2 || len(unknown) != 0 {
+		t.Errorf("reloaded Range = %+v, %v, want 2 tables and no unknown dates", res, unknown)
+	}
+}
+
+func TestGoldBackend(t *testing.T) {
+	s, path := openStore(t)
+	prices := map[string]svc.GoldPrice{
+		"2015-11-24": {Date: "2015-11-24", Price: "128.97"},
+		"2015-11-25": {Date: "2015-11-25", Price: "129.18"},
+	}
+	stub := &stubGoldBackend{prices: prices}
+	b := GoldBackend{Store: s, Backend: stub}
+
+	for i := 0; i < 2; i++ {
+		if p, err := b.GoldPrice(date("2015-11-24")); err != nil || p != prices["2015-11-24"] {
+			t.Errorf("GoldPrice(2015-11-24) = %+v, %v", p, err)
+		}
+		if _, err := b.GoldPrice(date("2015-11-22")); err != svc.ErrNotPublished {
+			t.Errorf("GoldPrice(2015-11-22) error = %v, want ErrNotPublished", err)
+		}
+	}
+	if stub.calls != 2 {
+		t.Errorf("%d calls to the backend, want 2", stub.calls)
+	}
+
+	stub.calls = 0
+	for i := 0; i < 2; i++ {
+		res, err := b.GoldPrices(date("2015-11-22"), date("2015-11-25"))
+		if err != nil || !reflect.DeepEqual(res, []svc.GoldPrice{prices["2015-11-24"], prices["2015-11-25"]}) {
+			t.Errorf("GoldPrices = %+v, %v", res, err)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("%d calls to the backend, want 1", stub.calls)
+	}
+
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	res, unknown := s2.GoldRange(date("2015-11-22"), date("2015-11-25"))
+	if len(res) != 2 || len(unknown) != 0 {
+		t.Errorf("reloaded GoldRange = %+v, %v, want 2 prices and no unknown dates", res, unknown)
+	}
+}
+
+func TestFailedWrite(t *testing.T) {
+	s, _ := openStore(t)
+	tables := map[string]svc.Query{"2015-11-24": table(t, "2015-11-24")}
+	prices := map[string]svc.GoldPrice{"2015-11-24": {Date: "2015-11-24", Price: "128.97"}}
+	b := Backend{Store: s, Backend: &stubBackend{tables: tables}}
+	gb := GoldBackend{Store: s, Backend: &stubGoldBackend{prices: prices}}
+
+	// Every write fails
+	s.Close()
+
+	if q, err := b.Table(date("2015-11-24"), "avg", "*"); err != nil || !reflect.DeepEqual(q, tables["2015-11-24"]) {
+		t.Errorf("Table = %+v, %v", q, err)
+	}
+	if res, err := b.Tables(date("2015-11-23"), date("2015-11-24"), "avg", "*"); err != nil || !reflect.DeepEqual(res, []svc.Query{tables["2015-11-24"]}) {
+		t.Errorf("Tables = %+v, %v", res, err)
+	}
+	if p, err := gb.GoldPrice(date("2015-11-24")); err != nil || p != prices["2015-11-24"] {
+		t.Errorf("GoldPrice = %+v, %v", p, err)
+	}
+	if res, err := gb.GoldPrices(date("2015-11-23"), date("2015-11-24")); err != nil || !reflect.DeepEqual(res, []svc.GoldPrice{prices["2015-11-24"]}) {
+		t.Errorf("GoldPrices = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,135 @@
+package svc
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	errWebAPIReply = "Couldn't decode the reply from NBP Web API"
+	// maxAPIDays is the longest period NBP Web API returns in one reply.
+	maxAPIDays = 93
+)
+
+// APIBackend reads the tables from NBP's Web API (api.nbp.pl).
+// Format is the format of the replies, "json" or "xml".
+// The Web API quotes every rate for a single unit of currency, so the Ratio is always "1",
+// the same as in the tables read by XMLBackend.
+type APIBackend struct {
+	Format string
+}
+
+type apiTables struct {
+	Tables []apiTable `xml:"ExchangeRatesTable"`
+}
+
+type apiTable struct {
+	No            string    `json:"no" xml:"No"`
+	EffectiveDate string    `json:"effectiveDate" xml:"EffectiveDate"`
+	Rates         []apiRate `json:"rates" xml:"Rates>Rate"`
+}
+
+type apiRate struct {
+	Currency string      `json:"currency" xml:"Currency"`
+	Code     string      `json:"code" xml:"Code"`
+	Mid      json.Number `json:"mid" xml:"Mid"`
+	Bid      json.Number `json:"bid" xml:"Bid"`
+	Ask      json.Number `json:"ask" xml:"Ask"`
+}
+
+// Table implements Backend.
+func (b APIBackend) Table(date time.Time, sType string, code string) (Query, error) {
+	tables, err := b.get(sType, date.Format("2006-01-02"))
+	if err != nil {
+		return Query{}, err
+	}
+	return FilterCurrencies(tables[0].query(), code), nil
+}
+
+// Tables implements Backend.
+// Longer periods are fetched in parts, as NBP Web API limits the length of one query.
+func (b APIBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]Query, error) {
+	res := []Query{}
+	for !from.After(to) {
+		end := from.AddDate(0, 0, maxAPIDays-1)
+		if end.After(to) {
+			end = to
+		}
+
+		tables, err := b.get(sType, from.Format("2006-01-02")+"/"+end.Format("2006-01-02"))
+		if err != nil && err != ErrNotPublished {
+			return nil, err
+		}
+		for _, t := range tables {
+			res = append(res, FilterCurrencies(t.query(), code))
+		}
+
+		from = end.AddDate(0, 0, 1)
+	}
+	return res, nil
+}
+
+// get fetches the tables of given type for the period, which is a date or two dates separated by slash.
+func (b APIBackend) get(sType string, period string) ([]apiTable, error) {
+	table := tableName(sType)
+	if table == "" {
+		return nil, errors.New("Unknown table type: " + sType)
+	}
+
+	format := b.Format
+	if format == "" {
+		format = "json"
+	}
+
+	resp, err := http.Get(nbpWebAPI + table + "/" + period + "/?format=" + format)
+	if err != nil {
+		return nil, errors.New(errNbpAPIProblem)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotPublished
+	default:
+		return nil, errors.New(errNbpAPIProblem)
+	}
+
+	var tables apiTables
+	if format == "xml" {
+		err = xml.NewDecoder(resp.Body).Decode(&tables)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&tables.Tables)
+	}
+	if err != nil || len(tables.Tables) == 0 {
+		return nil, errors.New(errWebAPIReply)
+	}
+	return tables.Tables, nil
+}
+
+// query converts the Web API table to the same Query as the one decoded from the XML files.
+func (t apiTable) query() Query {
+	q := Query{
+		FromData:    t.EffectiveDate,
+		TableNumber: t.No,
+	}
+	for _, r := range t.Rates {
+		q.Currencies = append(q.Currencies, currency{
+			Code:    r.Code,
+			Name:    r.Currency,
+			Ratio:   "1",
+			Average: formatRate(r.Mid),
+			Buy:     formatRate(r.Bid),
+			Sell:    formatRate(r.Ask),
+		})
+	}
+	return q
+}
+
+// formatRate formats the rate the way it's written in the XML files, e.g. 3.986 as "3,9860".
+func formatRate(n json.Number) string {
+	return formatNumber(n, 4)
+}
@@ -0,0 +1,209 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+)
+
+// ErrNotPublished is returned by a Backend when NBP has not published a table for the given date.
+var ErrNotPublished = errors.New("No table was published for the given date")
+
+// MinDate is the date of the first record in NBP.
+var MinDate = time.Date(2002, 1, 2, 0, 0, 0, 0, time.UTC)
+
+// Backend fetches currency tables from NBP.
+// Every implementation returns the same Query output, so callers don't care which one is used.
+type Backend interface {
+	// Table returns the table of given type ("avg" or "both") published on given date,
+	// filtered by code ("*" or comma separated codes like "USD,EUR").
+	// It returns ErrNotPublished if there is no table for that date.
+	Table(date time.Time, sType string, code string) (Query, error)
+	// Tables returns the tables of given type published between from and to (inclusive), ordered by date.
+	Tables(from time.Time, to time.Time, sType string, code string) ([]Query, error)
+}
+
+// NewBackend returns the backend with the given name.
+// "xml" (or empty) is the legacy /kursy/xml/ backend,
+// "api" and "api-xml" use api.nbp.pl with JSON and XML responses.
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "", "xml":
+		return XMLBackend{}, nil
+	case "api":
+		return APIBackend{Format: "json"}, nil
+	case "api-xml":
+		return APIBackend{Format: "xml"}, nil
+	}
+	return nil, errors.New("Unknown backend: " + name)
+}
+
+// XMLBackend reads the tables from XML files listed in the dir index.
+type XMLBackend struct{}
+
+// Table implements Backend.
+func (XMLBackend) Table(date time.Time, sType string, code string) (Query, error) {
+	f, err := GetResourceLocation(date.Format("2006-01-02"), sType)
+	if err != nil {
+		return Query{}, err
+	}
+	if f == "" {
+		return Query{}, ErrNotPublished
+	}
+	return GetData(f, code)
+}
+
+// Tables implements Backend.
+func (XMLBackend) Tables(from time.Time, to time.Time, sType string, code string) ([]Query, error) {
+	table := tableName(sType)
+	res := []Query{}
+	for year := from.Year(); year <= to.Year(); year++ {
+		entries, err := GetIndex(year)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if e.Type != table || e.Date.Before(from) || e.Date.After(to) {
+				continue
+			}
+			q, err := GetData(e.File, code)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, q)
+		}
+	}
+	return res, nil
+}
+
+// LastTable returns the table of given type published on the date or the last one before it.
+// The days the calendar knows no table is published on are skipped without asking NBP.
+// It returns ErrNotPublished if there is no table since the first record in NBP.
+func LastTable(b Backend, date time.Time, sType string, code string) (Query, error) {
+	if !calendar.IsPublicationDay(date) && date.After(MinDate) {
+		date = calendar.PreviousPublicationDay(date)
+	}
+
+	// When the table was not found for given date try to go back one day.
+	// It's used when NBP didn't publish the table on a day the calendar doesn't know about.
+	res, err := b.Table(date, sType, code)
+	for err == ErrNotPublished && date.After(MinDate) {
+		date = date.AddDate(0, 0, -1)
+		res, err = b.Table(date, sType, code)
+	}
+	return res, err
+}
+
+// ParseDate parses the date given in a request in one of the formats calendar.ParseDate accepts.
+// The date can't be in the future, with the days starting in Warsaw as NBP is there, or before min.
+// The error is meant to be shown to the client.
+func ParseDate(s string, min time.Time) (time.Time, error) {
+	date, err := calendar.ParseDate(s)
+	if err != nil {
+		return date, errors.New("Given date is wrong. Use 'YYYY-MM-DD', 'DD.MM.YYYY', 'YYYY-Www-D', 'YYYY-MM', 'today', 'yesterday', 'last-business-day' or '-Nd'")
+	}
+	if date.After(calendar.Today()) {
+		return date, errors.New("Given date is wrong. Can't use future date")
+	}
+	if date.Before(min) {
+		return date, errors.New("Given date is wrong. Min date is " + min.Format("2006-01-02"))
+	}
+	return date, nil
+}
+
+// MaxPeriodDays is the longest period the tables can be asked for in one request.
+const MaxPeriodDays = 366
+
+// CheckPeriod checks the period between from and to (inclusive) given in a request.
+// The error is meant to be shown to the client.
+func CheckPeriod(from time.Time, to time.Time) error {
+	if to.Before(from) {
+		return errors.New("Given dates are wrong. The end date can't be before the start date")
+	}
+	if to.After(from.AddDate(0, 0, MaxPeriodDays-1)) {
+		return fmt.Errorf("Given dates are wrong. The period can't be longer than %d days. Split it", MaxPeriodDays)
+	}
+	return nil
+}
+
+// Notice explains why q, the table found for the date by LastTable, is from another date,
+// e.g. "No table published on 2015-12-25: holiday (Boże Narodzenie)". It's empty when q is from the date.
+func Notice(date time.Time, q Query) string {
+	if q.FromData == date.Format("2006-01-02") {
+		return ""
+	}
+	notice := "No table published on " + date.Format("2006-01-02")
+	if reason := calendar.Reason(date); reason != "" {
+		notice += ": " + reason
+	}
+	return notice
+}
+
+// NotYetPublishedError is the error of asking for the table of today, when NBP hasn't published it yet.
+// Falling back to the latest table silently would look like today's rates.
+type NotYetPublishedError struct {
+	Date time.Time
+	// Type is the type of the table asked for ("avg" or "both"), they're published at different times.
+	Type string
+	// Latest is the table published before, found by LastTable instead.
+	Latest Query
+}
+
+func (e NotYetPublishedError) Error() string {
+	at := calendar.PublicationTime(e.Date, tableName(e.Type)).Format("15:04")
+	msg := "Table for " + e.Date.Format("2006-01-02") + " is not published yet"
+	if calendar.Published(e.Date, tableName(e.Type)) {
+		msg += ", although NBP usually publishes it by " + at + " Warsaw time"
+	} else {
+		msg += ", NBP publishes it around " + at + " Warsaw time"
+	}
+	return msg + ". The latest is " + e.Latest.TableNumber + " of " + e.Latest.FromData
+}
+
+// RetryAfter returns how long until NBP is expected to publish the table, not positive when it's overdue.
+func (e NotYetPublishedError) RetryAfter() time.Duration {
+	return time.Until(calendar.PublicationTime(e.Date, tableName(e.Type)))
+}
+
+// NotYetPublished reports whether q, the table found for the date by LastTable, is an older one
+// only because NBP hasn't published the table of the date yet, i.e. the date is today's publication day.
+func NotYetPublished(date time.Time, q Query) bool {
+	return date.Equal(calendar.Today()) && calendar.IsPublicationDay(date) && q.FromData != date.Format("2006-01-02")
+}
+
+// EachTable calls f with every table of given type published between from and to (inclusive), ordered by date.
+// The tables are fetched a month at a time, so long periods aren't kept in memory.
+// It stops at the first error returned by the backend or by f.
+func EachTable(b Backend, from time.Time, to time.Time, sType string, code string, f func(Query) error) error {
+	return eachMonth(from, to, func(from time.Time, to time.Time) error {
+		tables, err := b.Tables(from, to, sType, code)
+		if err != nil {
+			return err
+		}
+		for _, q := range tables {
+			if err := f(q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// eachMonth calls f with the parts of the period between from and to (inclusive) in every month, in order.
+// It stops at the first error returned by f.
+func eachMonth(from time.Time, to time.Time, f func(from time.Time, to time.Time) error) error {
+	for !from.After(to) {
+		end := time.Date(from.Year(), from.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		if end.After(to) {
+			end = to
+		}
+		if err := f(from, end); err != nil {
+			return err
+		}
+		from = end.AddDate(0, 0, 1)
+	}
+	return nil
+}
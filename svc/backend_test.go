@@ -0,0 +1,247 @@
+package svc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The same tables as published in the XML files, in ISO-8859-2, and by NBP Web API.
+var (
+	fixtureIndex = "\ufeffa228z151124\r\nc228z151124\r\na229z151125\r\nc229z151125\r\n"
+
+	fixtureFiles = map[string]string{
+		"a228z151124": `<?xml version="1.0" encoding="ISO-8859-2"?>
+<tabela_kursow typ="A" uid="15a228"><numer_tabeli>228/A/NBP/2015</numer_tabeli><data_publikacji>2015-11-24</data_publikacji>
+<pozycja><nazwa_waluty>dolar ameryka` + "\xf1" + `ski</nazwa_waluty><przelicznik>1</przelicznik><kod_waluty>USD</kod_waluty><kurs_sredni>3,8280</kurs_sredni></pozycja>
+<pozycja><nazwa_waluty>forint (W` + "\xea" + `gry)</nazwa_waluty><przelicznik>100</przelicznik><kod_waluty>HUF</kod_waluty><kurs_sredni>1,3720</kurs_sredni></pozycja>
+</tabela_kursow>`,
+		"c228z151124": `<?xml version="1.0" encoding="ISO-8859-2"?>
+<tabela_kursow typ="C" uid="15c228"><numer_tabeli>228/C/NBP/2015</numer_tabeli><data_notowania>2015-11-23</data_notowania><data_publikacji>2015-11-24</data_publikacji>
+<pozycja><nazwa_waluty>dolar ameryka` + "\xf1" + `ski</nazwa_waluty><przelicznik>1</przelicznik><kod_waluty>USD</kod_waluty><kurs_kupna>3,7897</kurs_kupna><kurs_sprzedazy>3,8663</kurs_sprzedazy></pozycja>
+<pozycja><nazwa_waluty>forint (W` + "\xea" + `gry)</nazwa_waluty><przelicznik>100</przelicznik><kod_waluty>HUF</kod_waluty><kurs_kupna>1,3583</kurs_kupna><kurs_sprzedazy>1,3857</kurs_sprzedazy></pozycja>
+</tabela_kursow>`,
+		"a229z151125": `<?xml version="1.0" encoding="ISO-8859-2"?>
+<tabela_kursow typ="A" uid="15a229"><numer_tabeli>229/A/NBP/2015</numer_tabeli><data_publikacji>2015-11-25</data_publikacji>
+<pozycja><nazwa_waluty>dolar ameryka` + "\xf1" + `ski</nazwa_waluty><przelicznik>1</przelicznik><kod_waluty>USD</kod_waluty><kurs_sredni>3,8290</kurs_sredni></pozycja>
+<pozycja><nazwa_waluty>forint (W` + "\xea" + `gry)</nazwa_waluty><przelicznik>100</przelicznik><kod_waluty>HUF</kod_waluty><kurs_sredni>1,2188</kurs_sredni></pozycja>
+</tabela_kursow>`,
+		"c229z151125": `<?xml version="1.0" encoding="ISO-8859-2"?>
+<tabela_kursow typ="C" uid="15c229"><numer_tabeli>229/C/NBP/2015</numer_tabeli><data_notowania>2015-11-24</data_notowania><data_publikacji>2015-11-25</data_publikacji>
+<pozycja><nazwa_waluty>dolar ameryka` + "\xf1" + `ski</nazwa_waluty><przelicznik>1</przelicznik><kod_waluty>USD</kod_waluty><kurs_kupna>3,7907</kurs_kupna><kurs_sprzedazy>3,8673</kurs_sprzedazy></pozycja>
+<pozycja><nazwa_waluty>forint (W` + "\xea" + `gry)</nazwa_waluty><przelicznik>100</przelicznik><kod_waluty>HUF</kod_waluty><kurs_kupna>1,3652</kurs_kupna><kurs_sprzedazy>1,3928</kurs_sprzedazy></pozycja>
+</tabela_kursow>`,
+	}
+
+	fixtureJSON = map[string]string{
+		"a/2015-11-24": `{"table":"A","no":"228/A/NBP/2015","effectiveDate":"2015-11-24","rates":[{"currency":"dolar amerykański","code":"USD","mid":3.828},{"currency":"forint (Węgry)","code":"HUF","mid":0.01372}]}`,
+		"c/2015-11-24": `{"table":"C","no":"228/C/NBP/2015","tradingDate":"2015-11-23","effectiveDate":"2015-11-24","rates":[{"currency":"dolar amerykański","code":"USD","bid":3.7897,"ask":3.8663},{"currency":"forint (Węgry)","code":"HUF","bid":0.013583,"ask":0.013857}]}`,
+		"a/2015-11-25": `{"table":"A","no":"229/A/NBP/2015","effectiveDate":"2015-11-25","rates":[{"currency":"dolar amerykański","code":"USD","mid":3.829},{"currency":"forint (Węgry)","code":"HUF","mid":0.012188}]}`,
+		"c/2015-11-25": `{"table":"C","no":"229/C/NBP/2015","tradingDate":"2015-11-24","effectiveDate":"2015-11-25","rates":[{"currency":"dolar amerykański","code":"USD","bid":3.7907,"ask":3.8673},{"currency":"forint (Węgry)","code":"HUF","bid":0.013652,"ask":0.013928}]}`,
+	}
+
+	fixtureXML = map[string]string{
+		"a/2015-11-24": `<ExchangeRatesTable><Table>A</Table><No>228/A/NBP/2015</No><EffectiveDate>2015-11-24</EffectiveDate><Rates><Rate><Currency>dolar amerykański</Currency><Code>USD</Code><Mid>3.828</Mid></Rate><Rate><Currency>forint (Węgry)</Currency><Code>HUF</Code><Mid>0.01372</Mid></Rate></Rates></ExchangeRatesTable>`,
+		"c/2015-11-24": `<ExchangeRatesTable><Table>C</Table><No>228/C/NBP/2015</No><TradingDate>2015-11-23</TradingDate><EffectiveDate>2015-11-24</EffectiveDate><Rates><Rate><Currency>dolar amerykański</Currency><Code>USD</Code><Bid>3.7897</Bid><Ask>3.8663</Ask></Rate><Rate><Currency>forint (Węgry)</Currency><Code>HUF</Code><Bid>0.013583</Bid><Ask>0.013857</Ask></Rate></Rates></ExchangeRatesTable>`,
+		"a/2015-11-25": `<ExchangeRatesTable><Table>A</Table><No>229/A/NBP/2015</No><EffectiveDate>2015-11-25</EffectiveDate><Rates><Rate><Currency>dolar amerykański</Currency><Code>USD</Code><Mid>3.829</Mid></Rate><Rate><Currency>forint (Węgry)</Currency><Code>HUF</Code><Mid>0.012188</Mid></Rate></Rates></ExchangeRatesTable>`,
+		"c/2015-11-25": `<ExchangeRatesTable><Table>C</Table><No>229/C/NBP/2015</No><TradingDate>2015-11-24</TradingDate><EffectiveDate>2015-11-25</EffectiveDate><Rates><Rate><Currency>dolar amerykański</Currency><Code>USD</Code><Bid>3.7907</Bid><Ask>3.8673</Ask></Rate><Rate><Currency>forint (Węgry)</Currency><Code>HUF</Code><Bid>0.013652</Bid><Ask>0.013928</Ask></Rate></Rates></ExchangeRatesTable>`,
+	}
+)
+
+// stubNBP serves the fixtures the way www.nbp.pl and api.nbp.pl do, until the test ends.
+func stubNBP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch p := r.URL.Path; {
+		case p == "/kursy/xml/dir2015.txt":
+			w.Write([]byte(fixtureIndex))
+		case strings.HasPrefix(p, "/kursy/xml/"):
+			f, ok := fixtureFiles[strings.TrimSuffix(strings.TrimPrefix(p, "/kursy/xml/"), ".xml")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(f))
+		case strings.HasPrefix(p, "/api/exchangerates/tables/"):
+			serveAPITables(w, r, strings.Trim(strings.TrimPrefix(p, "/api/exchangerates/tables/"), "/"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	xmlURL, webURL := nbpAPI, nbpWebAPI
+	nbpAPI, nbpWebAPI = srv.URL+"/kursy/xml/", srv.URL+"/api/exchangerates/tables/"
+	t.Cleanup(func() {
+		nbpAPI, nbpWebAPI = xmlURL, webURL
+		srv.Close()
+	})
+}
+
+// serveAPITables replies with the tables of the period given as type/date or type/from/to.
+func serveAPITables(w http.ResponseWriter, r *http.Request, period string) {
+	parts := strings.Split(period, "/")
+	from, _ := time.Parse("2006-01-02", parts[1])
+	to := from
+	if len(parts) > 2 {
+		to, _ = time.Parse("2006-01-02", parts[2])
+	}
+
+	var tables []string
+	fixture := fixtureJSON
+	if r.URL.Query().Get("format") == "xml" {
+		fixture = fixtureXML
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if t, ok := fixture[parts[0]+"/"+d.Format("2006-01-02")]; ok {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		http.Error(w, "404 NotFound - Not Found - Brak danych", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "xml" {
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><ArrayOfExchangeRatesTable xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+			strings.Join(tables, "") + `</ArrayOfExchangeRatesTable>`))
+		return
+	}
+	w.Write([]byte("[" + strings.Join(tables, ",") + "]"))
+}
+
+var backends = map[string]Backend{
+	"xml":     XMLBackend{},
+	"api":     APIBackend{Format: "json"},
+	"api-xml": APIBackend{Format: "xml"},
+}
+
+func TestBackendsTable(t *testing.T) {
+	stubNBP(t)
+	date := time.Date(2015, 11, 25, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		sType string
+		code  string
+		want  Query
+	}{
+		{"avg", "*", Query{FromData: "2015-11-25", TableNumber: "229/A/NBP/2015", Currencies: []currency{
+			{Code: "USD", Name: "dolar amerykański", Ratio: "1", Average: "3,8290"},
+			{Code: "HUF", Name: "forint (Węgry)", Ratio: "1", Average: "0,012188"},
+		}}},
+		{"both", "HUF", Query{FromData: "2015-11-25", TableNumber: "229/C/NBP/2015", Currencies: []currency{
+			{Code: "HUF", Name: "forint (Węgry)", Ratio: "1", Buy: "0,013652", Sell: "0,013928"},
+		}}},
+	}
+	for _, tt := range tests {
+		for name, b := range backends {
+			got, err := b.Table(date, tt.sType, tt.code)
+			if err != nil {
+				t.Errorf("%s: Table(%s, %s) failed: %v", name, tt.sType, tt.code, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Table(%s, %s) = %+v, want %+v", name, tt.sType, tt.code, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestBackendsTableNotPublished(t *testing.T) {
+	stubNBP(t)
+	for name, b := range backends {
+		if _, err := b.Table(time.Date(2015, 11, 28, 0, 0, 0, 0, time.UTC), "avg", "*"); err != ErrNotPublished {
+			t.Errorf("%s: Table on Saturday returned %v, want ErrNotPublished", name, err)
+		}
+	}
+}
+
+func TestBackendsTables(t *testing.T) {
+	stubNBP(t)
+	from, to := time.Date(2015, 11, 20, 0, 0, 0, 0, time.UTC), time.Date(2015, 11, 30, 0, 0, 0, 0, time.UTC)
+
+	want, err := XMLBackend{}.Tables(from, to, "both", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 2 || want[0].TableNumber != "228/C/NBP/2015" || want[1].TableNumber != "229/C/NBP/2015" {
+		t.Fatalf("XML backend returned %+v, want tables 228 and 229", want)
+	}
+	for name, b := range backends {
+		got, err := b.Tables(from, to, "both", "*")
+		if err != nil {
+			t.Errorf("%s: Tables failed: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Tables = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestLastTable(t *testing.T) {
+	stubNBP(t)
+	for name, b := range backends {
+		q, err := LastTable(b, time.Date(2015, 11, 26, 0, 0, 0, 0, time.UTC), "avg", "USD")
+		if err != nil {
+			t.Errorf("%s: LastTable failed: %v", name, err)
+			continue
+		}
+		if q.FromData != "2015-11-25" {
+			t.Errorf("%s: LastTable returned the table of %s, want 2015-11-25", name, q.FromData)
+		}
+	}
+}
+
+func TestPerUnit(t *testing.T) {
+	q := Query{Currencies: []currency{
+		{Code: "USD", Ratio: "1", Average: "3,8290"},
+		{Code: "HUF", Ratio: "100", Average: "1,2188", Buy: "1,3652", Sell: "1,3928"},
+		{Code: "IDR", Ratio: "10000", Average: "2,8016"},
+		{Code: "XXX", Ratio: "3", Average: "1,5000"},
+	}}
+	want := []currency{
+		{Code: "USD", Ratio: "1", Average: "3,8290"},
+		{Code: "HUF", Ratio: "1", Average: "0,012188", Buy: "0,013652", Sell: "0,013928"},
+		{Code: "IDR", Ratio: "1", Average: "0,00028016"},
+		{Code: "XXX", Ratio: "3", Average: "1,5000"},
+	}
+
+	got := PerUnit(q)
+	if !reflect.DeepEqual(got.Currencies, want) {
+		t.Errorf("PerUnit = %+v, want %+v", got.Currencies, want)
+	}
+	if q.Currencies[1].Ratio != "100" {
+		t.Error("PerUnit changed the given query")
+	}
+}
+
+func TestNotYetPublishedError(t *testing.T) {
+	latest := Query{FromData: "2015-11-24", TableNumber: "228/C/NBP/2015"}
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{"avg", "Table for 2015-11-25 is not published yet, although NBP usually publishes it by 12:15 Warsaw time. The latest is 228/C/NBP/2015 of 2015-11-24"},
+		{"both", "Table for 2015-11-25 is not published yet, although NBP usually publishes it by 08:15 Warsaw time. The latest is 228/C/NBP/2015 of 2015-11-24"},
+	}
+	date := time.Date(2015, 11, 25, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		e := NotYetPublishedError{Date: date, Type: tt.typ, Latest: latest}
+		if got := e.Error(); got != tt.want {
+			t.Errorf("%s: Error() = %q, want %q", tt.typ, got, tt.want)
+		}
+		if e.RetryAfter() > 0 {
+			t.Errorf("%s: RetryAfter() = %v for the overdue table", tt.typ, e.RetryAfter())
+		}
+	}
+
+	// Table C is due earlier than table A of the same day
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	avg := NotYetPublishedError{Date: tomorrow, Type: "avg"}.RetryAfter()
+	both := NotYetPublishedError{Date: tomorrow, Type: "both"}.RetryAfter()
+	if d := avg - both; d < 4*time.Hour-time.Second || d > 4*time.Hour+time.Second {
+		t.Errorf("table A is due %v after table C, want 4h", d)
+	}
+}
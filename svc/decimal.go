@@ -0,0 +1,159 @@
+package svc
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrNoCurrency is returned when the currency is not in the table.
+var ErrNoCurrency = errors.New("Currency not found in the table")
+
+// ParseDecimal parses the number written the way NBP does, with decimal comma, e.g. "3,9860".
+// Numbers with decimal point are accepted too.
+func ParseDecimal(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.Replace(strings.TrimSpace(s), ",", ".", 1))
+	if !ok {
+		return nil, errors.New("Couldn't parse the number: " + s)
+	}
+	return r, nil
+}
+
+// ratePlaces is the number of decimal places NBP gives the rates with, for the ratio units of the currency.
+// The rates for a single unit of the currencies quoted for more units have more, see PerUnit.
+const ratePlaces = 4
+
+// Places returns the number of decimal places of the number, e.g. 6 for "0,012188".
+func Places(s string) int {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, ",."); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// ExtraPlaces returns the number of decimal places the rate has over the usual ones, e.g. 2 for "0,012188".
+// The values computed from the rate need them too, not to lose the precision of the small rates.
+func ExtraPlaces(rate string) int {
+	if n := Places(rate) - ratePlaces; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// FormatDecimal formats the number with decimal comma, rounded to the given number of decimal places.
+func FormatDecimal(r *big.Rat, places int) string {
+	return strings.Replace(r.FloatString(places), ".", ",", 1)
+}
+
+// Rate returns the rate for a single unit of currency, i.e. the rate divided by the ratio (przelicznik).
+// E.g. the rate "1,2345" for 100 HUF gives 0.012345.
+func Rate(rate string, ratio string) (*big.Rat, error) {
+	r, err := ParseDecimal(rate)
+	if err != nil {
+		return nil, err
+	}
+	if ratio == "" {
+		return r, nil
+	}
+
+	n, err := ParseDecimal(ratio)
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() == 0 {
+		return nil, errors.New("Couldn't use the ratio: " + ratio)
+	}
+	return r.Quo(r, n), nil
+}
+
+// PerUnit returns the query with every rate given for a single unit of the currency, with ratio "1",
+// the way NBP Web API gives them. E.g. the rate "1,2188" for 100 HUF becomes "0,012188".
+// The rates keep all their digits, as NBP's ratios are powers of ten.
+func PerUnit(q Query) Query {
+	res := q
+	res.Currencies = make([]currency, 0, len(q.Currencies))
+	for _, c := range q.Currencies {
+		if c.Ratio != "1" {
+			if zeros := strings.TrimPrefix(c.Ratio, "1"); zeros != c.Ratio && strings.Trim(zeros, "0") == "" {
+				c.Average = shift(c.Average, len(zeros))
+				c.Buy = shift(c.Buy, len(zeros))
+				c.Sell = shift(c.Sell, len(zeros))
+				c.Ratio = "1"
+			}
+		}
+		res.Currencies = append(res.Currencies, c)
+	}
+	return res
+}
+
+// shift divides the rate by 10 to the power of n, keeping all its digits.
+func shift(rate string, n int) string {
+	r, err := ParseDecimal(rate)
+	if err != nil {
+		return rate
+	}
+	div := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+	return FormatDecimal(r.Quo(r, div), Places(rate)+n)
+}
+
+// PLNRate returns the average rate of a single unit of the currency in PLN from table A.
+// The rate of PLN itself is 1.
+func PLNRate(q Query, code string) (*big.Rat, error) {
+	if code == "PLN" {
+		return big.NewRat(1, 1), nil
+	}
+	for _, c := range q.Currencies {
+		if c.Code == code {
+			return Rate(c.Average, c.Ratio)
+		}
+	}
+	return nil, ErrNoCurrency
+}
+
+// Convert converts the amount between two currencies using the average rates from table A.
+func Convert(q Query, amount *big.Rat, from string, to string) (*big.Rat, error) {
+	fromRate, err := PLNRate(q, from)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := PLNRate(q, to)
+	if err != nil {
+		return nil, err
+	}
+	if toRate.Sign() == 0 {
+		return nil, errors.New("Couldn't use the rate of " + to)
+	}
+
+	res := new(big.Rat).Mul(amount, fromRate)
+	return res.Quo(res, toRate), nil
+}
+
+// Rebase returns table A with the rates expressed in the base currency instead of PLN, with the given number of decimal places,
+// more for the small rates, see ExtraPlaces. PLN is added to the table, unless it's the base.
+func Rebase(q Query, base string, places int) (Query, error) {
+	baseRate, err := PLNRate(q, base)
+	if err != nil {
+		return Query{}, err
+	}
+	if baseRate.Sign() == 0 {
+		return Query{}, errors.New("Couldn't use the rate of " + base)
+	}
+	if base == "PLN" {
+		return q, nil
+	}
+
+	res := Query{FromData: q.FromData, TableNumber: q.TableNumber}
+	for _, c := range q.Currencies {
+		r, err := ParseDecimal(c.Average)
+		if err != nil {
+			return Query{}, err
+		}
+		c.Average = FormatDecimal(r.Quo(r, baseRate), places+ExtraPlaces(c.Average))
+		res.Currencies = append(res.Currencies, c)
+	}
+
+	pln := new(big.Rat).Inv(baseRate)
+	res.Currencies = append(res.Currencies, currency{Code: "PLN", Name: "złoty polski", Ratio: "1", Average: FormatDecimal(pln, places)})
+	return res, nil
+}
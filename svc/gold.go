@@ -0,0 +1,159 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+)
+
+// GoldMinDate is the date of the first gold price published in NBP Web API.
+var GoldMinDate = time.Date(2013, 1, 2, 0, 0, 0, 0, time.UTC)
+
+// GoldPrice is the price of 1g of gold (of 1000 millesimal fineness) calculated by NBP.
+type GoldPrice struct {
+	Date  string `json:"date"`
+	Price string `json:"price"`
+}
+
+// GoldBackend fetches the gold prices from NBP.
+type GoldBackend interface {
+	// GoldPrice returns the gold price published on given date.
+	// It returns ErrNotPublished if there is no price for that date.
+	GoldPrice(date time.Time) (GoldPrice, error)
+	// GoldPrices returns the gold prices published between from and to (inclusive), ordered by date.
+	GoldPrices(from time.Time, to time.Time) ([]GoldPrice, error)
+}
+
+// APIGoldBackend reads the gold prices from NBP Web API, the only source of them.
+type APIGoldBackend struct{}
+
+// GoldPrice implements GoldBackend.
+func (APIGoldBackend) GoldPrice(date time.Time) (GoldPrice, error) {
+	return GetGoldPrice(date)
+}
+
+// GoldPrices implements GoldBackend.
+func (APIGoldBackend) GoldPrices(from time.Time, to time.Time) ([]GoldPrice, error) {
+	return GetGoldPrices(from, to)
+}
+
+// LastGoldPrice returns the gold price published on the date or the last one before it.
+// The days the calendar knows nothing is published on are skipped without asking NBP.
+// It returns ErrNotPublished if there is no price since GoldMinDate.
+func LastGoldPrice(b GoldBackend, date time.Time) (GoldPrice, error) {
+	if !calendar.IsPublicationDay(date) && date.After(GoldMinDate) {
+		date = calendar.PreviousPublicationDay(date)
+	}
+
+	// NBP might still not publish the price on a day the calendar doesn't know about
+	res, err := b.GoldPrice(date)
+	for err == ErrNotPublished && date.After(GoldMinDate) {
+		date = calendar.PreviousPublicationDay(date)
+		res, err = b.GoldPrice(date)
+	}
+	return res, err
+}
+
+// EachGoldPrice calls f with every gold price published between from and to (inclusive), ordered by date.
+// The prices are fetched a month at a time, the same as EachTable does with the tables.
+// It stops at the first error returned by the backend or by f.
+func EachGoldPrice(b GoldBackend, from time.Time, to time.Time, f func(GoldPrice) error) error {
+	return eachMonth(from, to, func(from time.Time, to time.Time) error {
+		prices, err := b.GoldPrices(from, to)
+		if err != nil {
+			return err
+		}
+		for _, p := range prices {
+			if err := f(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type apiGoldPrice struct {
+	Date  string      `json:"data"`
+	Price json.Number `json:"cena"`
+}
+
+// GetGoldPrice returns the gold price published on given date.
+// It returns ErrNotPublished if there is no price for that date.
+func GetGoldPrice(date time.Time) (GoldPrice, error) {
+	prices, err := getGoldPrices(date.Format("2006-01-02"))
+	if err != nil {
+		return GoldPrice{}, err
+	}
+	return prices[0], nil
+}
+
+// GetGoldPrices returns the gold prices published between from and to (inclusive).
+// Longer periods are fetched in parts, as NBP Web API limits the length of one query.
+func GetGoldPrices(from time.Time, to time.Time) ([]GoldPrice, error) {
+	prices := []GoldPrice{}
+	for !from.After(to) {
+		end := from.AddDate(0, 0, maxAPIDays-1)
+		if end.After(to) {
+			end = to
+		}
+
+		p, err := getGoldPrices(from.Format("2006-01-02") + "/" + end.Format("2006-01-02"))
+		if err != nil && err != ErrNotPublished {
+			return nil, err
+		}
+		prices = append(prices, p...)
+
+		from = end.AddDate(0, 0, 1)
+	}
+	return prices, nil
+}
+
+// getGoldPrices fetches the gold prices for the period, which is a date or two dates separated by slash.
+func getGoldPrices(period string) ([]GoldPrice, error) {
+	resp, err := http.Get(nbpGoldAPI + period + "/?format=json")
+	if err != nil {
+		return nil, errors.New(errNbpAPIProblem)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotPublished
+	default:
+		return nil, errors.New(errNbpAPIProblem)
+	}
+
+	var res []apiGoldPrice
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || len(res) == 0 {
+		return nil, errors.New(errWebAPIReply)
+	}
+
+	prices := make([]GoldPrice, 0, len(res))
+	for _, p := range res {
+		prices = append(prices, GoldPrice{Date: p.Date, Price: formatNumber(p.Price, 2)})
+	}
+	return prices, nil
+}
+
+// formatNumber formats the number the way rates are written in the XML files,
+// with decimal comma and at least given number of decimal places, e.g. 125.4 as "125,40".
+func formatNumber(n json.Number, places int) string {
+	s := string(n)
+	if s == "" {
+		return ""
+	}
+	i := strings.Index(s, ".")
+	if i < 0 {
+		s += "."
+		i = len(s) - 1
+	}
+	for len(s)-i-1 < places {
+		s += "0"
+	}
+	return strings.Replace(s, ".", ",", 1)
+}
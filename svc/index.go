@@ -0,0 +1,153 @@
+package svc
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karolgorecki/nbp/calendar"
+)
+
+// ErrBadTableNumber is returned by FindEntry when the table number is not like 229/A/NBP/2015.
+var ErrBadTableNumber = errors.New("Table number should be like 229/A/NBP/2015")
+
+// Entry is a file listed in the dir index, e.g. a229z151125.
+type Entry struct {
+	// File is the name of the file without the .xml extension.
+	File string
+	// Type is the letter of the table: a, b, c or h.
+	Type string
+	// Number is the number of the table in the year, e.g. 229 of 229/A/NBP/2015.
+	Number int
+	// Date is the publication date.
+	Date time.Time
+}
+
+// GetIndex returns the files of the given year listed in the index.
+// E.g.: dir2015.txt - contains references to files that have currencies for 2015 year
+// E.g.: dir.txt - contains references for the current year.
+// NBP doesn't switch the files at midnight of New Year: dir.txt lists the last year's files
+// until the first table of the new year, and dirYYYY.txt of the last year appears some days later.
+// So the other file is tried when the first one is missing or doesn't list the year,
+// and only the entries of the given year are returned.
+func GetIndex(year int) ([]Entry, error) {
+	files := []string{"dir" + strconv.Itoa(year) + ".txt", "dir.txt"}
+	if year == calendar.Today().Year() {
+		files[0], files[1] = files[1], files[0]
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		all, err := getIndexFile(f)
+		if err == errNoIndex {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range all {
+			if e.Date.Year() == year {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) > 0 {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// errNoIndex is returned by getIndexFile when NBP doesn't have the file.
+var errNoIndex = errors.New("No such index")
+
+// getIndexFile returns the files listed in the index file, e.g. dir.txt.
+func getIndexFile(name string) ([]Entry, error) {
+	resp, err := http.Get(nbpAPI + name)
+	if err != nil {
+		return nil, errors.New(errNbpAPIProblem)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNoIndex
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(errNbpAPIProblem)
+	}
+
+	var entries []Entry
+	scn := bufio.NewScanner(resp.Body)
+	for scn.Scan() {
+		if e, ok := parseEntry(scn.Text()); ok {
+			entries = append(entries, e)
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return nil, errors.New(errNbpAPIProblem)
+	}
+	return entries, nil
+}
+
+// parseEntry parses the line of the index. The file name is the table letter,
+// the table number, the letter z and the publication date, e.g. a229z151125.
+func parseEntry(line string) (Entry, bool) {
+	// The first line starts with the byte order mark
+	file := strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+	if len(file) < 7 {
+		return Entry{}, false
+	}
+
+	date, err := time.Parse("060102", file[len(file)-6:])
+	if err != nil {
+		return Entry{}, false
+	}
+	number, _ := strconv.Atoi(file[1 : len(file)-7])
+	return Entry{File: file, Type: file[:1], Number: number, Date: date}, true
+}
+
+// TableType returns the type of the table ("avg" or "both"), or an empty string for the tables of other types.
+func (e Entry) TableType() string {
+	switch e.Type {
+	case avg:
+		return "avg"
+	case both:
+		return "both"
+	}
+	return ""
+}
+
+// FindEntry returns the entry of the table with given number, e.g. "229/A/NBP/2015".
+// It returns ErrBadTableNumber if the number is malformed and ErrNotPublished if the index doesn't list the table.
+func FindEntry(number string) (Entry, error) {
+	parts := strings.Split(number, "/")
+	if len(parts) != 4 || len(parts[1]) != 1 || parts[2] != "NBP" {
+		return Entry{}, ErrBadTableNumber
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n < 1 {
+		return Entry{}, ErrBadTableNumber
+	}
+	year, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return Entry{}, ErrBadTableNumber
+	}
+	if year < MinDate.Year() || year > calendar.Today().Year() {
+		return Entry{}, ErrNotPublished
+	}
+
+	entries, err := GetIndex(year)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	table := strings.ToLower(parts[1])
+	for _, e := range entries {
+		if e.Type == table && e.Number == n {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotPublished
+}
@@ -0,0 +1,95 @@
+package svc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/karolgorecki/nbp/calendar"
+)
+
+// stubIndex serves the index files by name, until the test ends. The other files are missing.
+func stubIndex(t *testing.T, files map[string]string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := files[strings.TrimPrefix(r.URL.Path, "/kursy/xml/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(f))
+	}))
+
+	prev := nbpAPI
+	nbpAPI = srv.URL + "/kursy/xml/"
+	t.Cleanup(func() {
+		nbpAPI = prev
+		srv.Close()
+	})
+}
+
+func TestGetIndexAcrossYears(t *testing.T) {
+	// The year of today decides which file is tried first, so the files are named after it
+	year := calendar.Today().Year()
+	cur, last := fmt.Sprint(year), fmt.Sprint(year-1)
+	lastFiles := []string{
+		fmt.Sprintf("a251z%02d1230", (year-1)%100),
+		fmt.Sprintf("a252z%02d1231", (year-1)%100),
+	}
+	curFiles := []string{fmt.Sprintf("a001z%02d0102", year%100)}
+	index := func(files ...string) string {
+		return "\ufeff" + strings.Join(files, "\r\n") + "\r\n"
+	}
+	both := append(append([]string{}, lastFiles...), curFiles...)
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		year  int
+		want  []string
+	}{
+		// Before the first table of the year dir.txt still lists the last year
+		{"this year not in dir.txt", map[string]string{
+			"dir.txt":             index(lastFiles...),
+			"dir" + cur + ".txt":  index(curFiles...),
+			"dir" + last + ".txt": index(lastFiles...),
+		}, year, curFiles},
+		{"this year not published", map[string]string{
+			"dir.txt": index(lastFiles...),
+		}, year, nil},
+		// and the file of the last year isn't there yet
+		{"last year only in dir.txt", map[string]string{
+			"dir.txt": index(both...),
+		}, year - 1, lastFiles},
+		{"last year in its file", map[string]string{
+			"dir.txt":             index(curFiles...),
+			"dir" + last + ".txt": index(lastFiles...),
+		}, year - 1, lastFiles},
+		// After the first table dir.txt lists both years
+		{"this year in dir.txt", map[string]string{
+			"dir.txt": index(both...),
+		}, year, curFiles},
+	}
+
+	for _, tt := range tests {
+		stubIndex(t, tt.files)
+
+		entries, err := GetIndex(tt.year)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.File)
+			if e.Date.Year() != tt.year {
+				t.Errorf("%s: %s is from %v", tt.name, e.File, e.Date.Format("2006-01-02"))
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: GetIndex(%d) = %v, want %v", tt.name, tt.year, got, tt.want)
+		}
+	}
+}
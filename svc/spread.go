@@ -0,0 +1,56 @@
+package svc
+
+import (
+	"errors"
+	"math/big"
+)
+
+// PercentPlaces is the number of decimal places of the percentages, like the spread percentage.
+// The spread has as many as the rates and the mid price one more, as it's their half.
+const PercentPlaces = 4
+
+// CurrencySpread returns the mid price, the spread and the spread as the percentage of the mid price,
+// for the buy and sell rates from table C.
+func CurrencySpread(buy string, sell string) (mid *big.Rat, spread *big.Rat, percent *big.Rat, err error) {
+	b, err := ParseDecimal(buy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s, err := ParseDecimal(sell)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	mid = new(big.Rat).Add(b, s)
+	mid.Quo(mid, big.NewRat(2, 1))
+	if mid.Sign() == 0 {
+		return nil, nil, nil, errors.New("Couldn't use the rates " + buy + " and " + sell)
+	}
+	spread = new(big.Rat).Sub(s, b)
+	percent = new(big.Rat).Quo(spread, mid)
+	percent.Mul(percent, big.NewRat(100, 1))
+	return mid, spread, percent, nil
+}
+
+// Spreads returns the table with the mid price, the spread and the spread percentage
+// of every currency having both buy and sell rates.
+func Spreads(q Query) Query {
+	// Don't change the currencies of q, the query may be kept by the caller
+	res := q
+	res.Currencies = make([]currency, len(q.Currencies))
+	for i, c := range q.Currencies {
+		if c.Buy != "" && c.Sell != "" {
+			if mid, spread, percent, err := CurrencySpread(c.Buy, c.Sell); err == nil {
+				places := Places(c.Buy)
+				if p := Places(c.Sell); p > places {
+					places = p
+				}
+				c.Mid = FormatDecimal(mid, places+1)
+				c.Spread = FormatDecimal(spread, places)
+				c.SpreadPercent = FormatDecimal(percent, PercentPlaces)
+			}
+		}
+		res.Currencies[i] = c
+	}
+	return res
+}
@@ -1,7 +1,6 @@
 package svc
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/xml"
 	"errors"
@@ -18,112 +17,102 @@ import (
 const (
 	// a - tabela kursów średnich walut obcych;
 	// c - tabela kursów kupna i sprzedaży;
-	avg                = "a"
-	both               = "c"
-	nbpAPI             = "http://www.nbp.pl/kursy/xml/"
-	errCannotParseDate = "Couldn't parse the given date"
-	errNbpAPIProblem   = "Couldn't get data from NBP API"
+	avg                  = "a"
+	both                 = "c"
+	errCannotParseDate   = "Couldn't parse the given date"
+	errNbpAPIProblem     = "Couldn't get data from NBP API"
+	errCannotDecodeTable = "Couldn't decode the table from NBP API"
 )
 
-func getFormatedDate(date string) (string, error) {
-	tmpDate, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		return "", errors.New("Couldn't not parse the given date")
-	}
-	date = tmpDate.Format("060102")
-	return date, nil
-}
+// The addresses of NBP services are variables, so the tests can serve them from a local server.
+var (
+	// nbpAPI serves the XML files with the tables and the dir index.
+	nbpAPI = "http://www.nbp.pl/kursy/xml/"
+	// nbpWebAPI serves the tables in NBP Web API.
+	nbpWebAPI = "http://api.nbp.pl/api/exchangerates/tables/"
+	// nbpGoldAPI serves the gold prices in NBP Web API.
+	nbpGoldAPI = "http://api.nbp.pl/api/cenyzlota/"
+)
 
-func getFormatedYear(date string) (string, error) {
-	tmpDate, err := time.Parse("2006-01-02", date)
+// GetResourceLocation returns name of file that contains the currencies for given date
+// We're searching the index which is just a txt file, see GetIndex.
+func GetResourceLocation(sDate string, sType string) (string, error) {
+	date, err := time.Parse("2006-01-02", sDate)
 	if err != nil {
 		return "", errors.New(errCannotParseDate)
 	}
 
-	fullYear := tmpDate.Format("2006")
-	currentYear := time.Now().Format("2006")
-
-	yr := ""
-	if currentYear != fullYear {
-		yr = fullYear
-	}
-
-	return yr, nil
-}
-
-// GetResourceLocation returns name of file that contains the currencies for given date
-// We're searching the index which is just a txt file.
-// E.g.: dir2015.txt - contains references to files that have currencies for 2015 year
-// E.g.: dir.txt - contains references for the current year.
-func GetResourceLocation(sDate string, sType string) (string, error) {
-
-	yr, err := getFormatedYear(sDate)
-	fmtDate, err := getFormatedDate(sDate)
-	resp, err := http.Get(nbpAPI + "dir" + yr + ".txt")
-	defer func() {
-		err = resp.Body.Close()
-	}()
+	entries, err := GetIndex(date.Year())
 	if err != nil {
-		return "", errors.New(errNbpAPIProblem)
+		return "", err
 	}
 
-	lns := bufio.NewReader(resp.Body)
-	scn := bufio.NewScanner(lns)
-
-	fLsts := []string{}
-
-	for scn.Scan() {
-		if strings.Contains(scn.Text(), fmtDate) {
-			fLsts = append(fLsts, scn.Text())
+	table := tableName(sType)
+	for _, e := range entries {
+		if e.Type == table && e.Date.Equal(date) {
+			return e.File, nil
 		}
 	}
+	return "", nil
+}
 
-	var resourceName string
-	for _, entry := range fLsts {
-		switch sType {
-		case "avg":
-			if string(entry[0]) == avg {
-				resourceName = string(entry)
-			}
-		case "both":
-			if string(entry[0]) == both {
-				resourceName = string(entry)
-			}
-		default:
-			resourceName = ""
-		}
+// tableName returns the letter of the table with given type ("avg" or "both").
+// It returns an empty string for unknown types.
+func tableName(sType string) string {
+	switch sType {
+	case "avg":
+		return avg
+	case "both":
+		return both
 	}
-	return resourceName, nil
+	return ""
 }
 
 // GetData fetches the currency/currencies in given file.
 // There is one file for one date. E.g.: 2015-01-02 is a20123123.xml
+// The rates are given for a single unit of the currency, like by NBP Web API, see PerUnit.
 func GetData(file string, code string) (Query, error) {
 
 	resp, err := http.Get(nbpAPI + file + ".xml")
+	if err != nil {
+		return Query{}, errors.New("Couldn't not get data from NBP api")
+	}
 	defer func() {
 		err = resp.Body.Close()
 	}()
-	if err != nil {
+	if resp.StatusCode != http.StatusOK {
 		return Query{}, errors.New("Couldn't not get data from NBP api")
 	}
 
 	var q Query
-	currencyData, _ := ioutil.ReadAll(resp.Body)
+	currencyData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Query{}, errors.New("Couldn't not get data from NBP api")
+	}
 	cData := bytes.NewReader(currencyData)
 
 	decoder := xml.NewDecoder(cData)
 	decoder.CharsetReader = charset.NewReader
-	err = decoder.Decode(&q)
+	if err = decoder.Decode(&q); err != nil {
+		return Query{}, errors.New(errCannotDecodeTable)
+	}
 
-	res := q
-	res.Currencies = res.Currencies[:0]
+	return FilterCurrencies(PerUnit(q), code), nil
+}
 
+// FilterCurrencies leaves only the currencies with given codes in the query.
+// The code is "*" for all currencies or comma separated codes like "USD,EUR".
+func FilterCurrencies(q Query, code string) Query {
 	if code == "*" {
-		return q, nil
+		return q
 	}
 
 	codes := strings.Split(code, ",")
+
+	// Don't reuse the currencies of q, the query may be kept by the caller
+	res := q
+	res.Currencies = make([]currency, 0, len(codes))
+
 	for _, c := range q.Currencies {
 
 		for _, cd := range codes {
@@ -132,7 +121,7 @@ func GetData(file string, code string) (Query, error) {
 			}
 		}
 	}
-	return res, nil
+	return res
 }
 
 // Query ...
@@ -149,4 +138,8 @@ type currency struct {
 	Average string `xml:"kurs_sredni" json:"average"`
 	Buy     string `xml:"kurs_kupna" json:"buy"`
 	Sell    string `xml:"kurs_sprzedazy" json:"sell"`
+	// Mid, Spread and SpreadPercent are computed from Buy and Sell by Spreads, they're not published by NBP.
+	Mid           string `xml:"-" json:"mid,omitempty"`
+	Spread        string `xml:"-" json:"spread,omitempty"`
+	SpreadPercent string `xml:"-" json:"spreadPercent,omitempty"`
 }
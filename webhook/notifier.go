@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/karolgorecki/nbp/poller"
+)
+
+// event is the payload sent to the webhooks.
+type event struct {
+	Event    string `json:"event"`
+	Delivery string `json:"delivery"`
+	poller.Publication
+}
+
+// Notifier delivers the new publications to the registered webhooks.
+// A failed delivery is retried, waiting twice as long before every next attempt.
+type Notifier struct {
+	Registry *Registry
+	Client   *http.Client
+	Attempts int
+	Backoff  time.Duration
+}
+
+// NewNotifier returns the notifier making 5 attempts, starting with 10 seconds between them.
+// Its client connects only to public addresses, see NewClient.
+func NewNotifier(r *Registry) *Notifier {
+	return &Notifier{
+		Registry: r,
+		Client:   NewClient(),
+		Attempts: 5,
+		Backoff:  10 * time.Second,
+	}
+}
+
+// errNotPublic is returned when the webhook resolves to an address it can't be sent to.
+var errNotPublic = errors.New("the webhook doesn't resolve to a public address")
+
+// NewClient returns the client delivering the webhooks. It checks the address of every connection it makes,
+// so the webhook can't reach the services behind the server even if its host resolves differently than on registration,
+// or it redirects elsewhere. No proxy is used, as the client has to see the address it connects to.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !Public(ip) {
+				return errNotPublic
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+}
+
+// Run notifies the webhooks about every publication read from the channel, until it's closed.
+func (n *Notifier) Run(pubs <-chan poller.Publication) {
+	for pub := range pubs {
+		for _, h := range n.Registry.List() {
+			if h.wants(pub.Type) {
+				go n.deliver(h, pub)
+			}
+		}
+	}
+}
+
+func (n *Notifier) deliver(h Hook, pub poller.Publication) {
+	id, err := randomHex(8)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	body, err := json.Marshal(event{Event: "table.published", Delivery: id, Publication: pub})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	wait := n.Backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(h, id, body)
+		if err == nil {
+			return
+		}
+		// The address won't become public by retrying
+		if attempt >= n.Attempts || errors.Is(err, errNotPublic) {
+			log.Printf("webhook %s: giving up delivery %s of %s: %v", h.ID, id, pub.File, err)
+			return
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (n *Notifier) post(h Hook, id string, body []byte) error {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	req.Header.Set("X-NBP-Event", "table.published")
+	req.Header.Set("X-NBP-Delivery", id)
+	req.Header.Set("X-NBP-Signature", Sign(h.Secret, body))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the body: "sha256=" and the hex encoded HMAC-SHA256 of the body with the secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientRefusesNonPublic(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer srv.Close()
+
+	// The hook registered before the addresses were checked, or resolving to loopback now
+	n := &Notifier{Client: NewClient(), Attempts: 1}
+	err := n.post(Hook{ID: "1", URL: srv.URL, Secret: "s"}, "d", []byte("{}"))
+	if !errors.Is(err, errNotPublic) {
+		t.Errorf("post to %s = %v, want %v", srv.URL, err, errNotPublic)
+	}
+	if called {
+		t.Error("the webhook was delivered to loopback address")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"event":"table.published"}' | openssl dgst -sha256 -hmac secret
+	got := Sign("secret", []byte(`{"event":"table.published"}`))
+	want := "sha256=8ebad24b3e0a05dd4d999da55ec78aaefcbcd19b887b624140367199fdadfb02"
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+// Package webhook notifies the registered URLs when a new table is published.
+//
+// Every notification is a POST request with JSON payload signed with the secret of the webhook:
+// X-NBP-Signature header holds "sha256=" and the hex encoded HMAC-SHA256 of the body.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Hook is a registered webhook.
+type Hook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Types are the types of the tables ("avg" or "both") the webhook is notified about, all if empty.
+	Types []string `json:"types"`
+}
+
+// wants reports whether the webhook is notified about the tables of given type.
+func (h Hook) wants(sType string) bool {
+	if len(h.Types) == 0 {
+		return true
+	}
+	for _, t := range h.Types {
+		if t == sType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the URL and types of the webhook.
+// The URL has to point at public addresses, so the webhooks can't be used to reach the services behind the server.
+func Validate(h Hook) error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("Given url is wrong. Use absolute http or https URL")
+	}
+	ips, err := lookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return errors.New("Given url is wrong. Its host couldn't be resolved")
+	}
+	for _, ip := range ips {
+		if !Public(ip) {
+			return errors.New("Given url is wrong. Use public address, not loopback, private or link-local one")
+		}
+	}
+	for _, t := range h.Types {
+		if t != "avg" && t != "both" {
+			return errors.New("Given type is wrong. Use 'avg' or 'both'")
+		}
+	}
+	return nil
+}
+
+// lookupIP resolves the host of the webhook. An IP address is returned as it is.
+var lookupIP = net.LookupIP
+
+// nonPublic are the networks not covered by the net.IP methods the webhooks can't be sent to:
+// "this network" and the shared address space used by carrier-grade NAT.
+var nonPublic = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Public reports whether the webhooks can be sent to the address,
+// i.e. it's not loopback, private, link-local, multicast or unspecified one.
+func Public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublic {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry keeps the webhooks in a JSON file.
+// It's safe for concurrent use.
+type Registry struct {
+	path string
+
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+// Open loads the webhooks from the file. The file is created when the first webhook is added.
+func Open(path string) (*Registry, error) {
+	r := &Registry{path: path, hooks: []Hook{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.hooks); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns all webhooks.
+func (r *Registry) List() []Hook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Hook{}, r.hooks...)
+}
+
+// Get returns the webhook with given id.
+func (r *Registry) Get(id string) (Hook, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return Hook{}, false
+	}
+	return r.hooks[i], true
+}
+
+// Add registers the webhook with a new id. The secret is generated unless it's given.
+func (r *Registry) Add(h Hook) (Hook, error) {
+	if err := Validate(h); err != nil {
+		return Hook{}, err
+	}
+
+	if h.Types == nil {
+		h.Types = []string{}
+	}
+
+	var err error
+	if h.ID, err = randomHex(8); err != nil {
+		return Hook{}, err
+	}
+	if h.Secret == "" {
+		if h.Secret, err = randomHex(32); err != nil {
+			return Hook{}, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, h)
+	if err := r.save(); err != nil {
+		r.hooks = r.hooks[:len(r.hooks)-1]
+		return Hook{}, err
+	}
+	return h, nil
+}
+
+// Update replaces the URL and types of the webhook with given id, and its secret if a new one is given.
+// The second result is false if there is no such webhook.
+func (r *Registry) Update(id string, h Hook) (Hook, bool, error) {
+	if err := Validate(h); err != nil {
+		return Hook{}, true, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return Hook{}, false, nil
+	}
+
+	old := r.hooks[i]
+	h.ID = id
+	if h.Types == nil {
+		h.Types = []string{}
+	}
+	if h.Secret == "" {
+		h.Secret = old.Secret
+	}
+	r.hooks[i] = h
+	if err := r.save(); err != nil {
+		r.hooks[i] = old
+		return Hook{}, true, err
+	}
+	return h, true, nil
+}
+
+// Delete removes the webhook with given id. It returns false if there is no such webhook.
+func (r *Registry) Delete(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return false, nil
+	}
+
+	old := r.hooks
+	r.hooks = append(append([]Hook{}, old[:i]...), old[i+1:]...)
+	if err := r.save(); err != nil {
+		r.hooks = old
+		return true, err
+	}
+	return true, nil
+}
+
+func (r *Registry) index(id string) int {
+	for i, h := range r.hooks {
+		if h.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the webhooks to a temporary file and renames it, so the file is never left half written.
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r.hooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(r.path+".tmp", r.path)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"fd12:3456::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		// IPv4-mapped IPv6 addresses are checked as IPv4
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+	for _, tt := range tests {
+		if got := Public(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Public(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	hosts := map[string][]net.IP{
+		"example.com":   {net.ParseIP("93.184.216.34")},
+		"localhost":     {net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		"internal.test": {net.ParseIP("10.0.0.5")},
+		// One of the addresses is enough, as any of them can be used
+		"mixed.test": {net.ParseIP("93.184.216.34"), net.ParseIP("192.168.0.1")},
+	}
+	lookup := lookupIP
+	defer func() { lookupIP = lookup }()
+	lookupIP = func(host string) ([]net.IP, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, nil
+		}
+		if ips, ok := hosts[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		url   string
+		types []string
+		ok    bool
+	}{
+		{"https://example.com/nbp", nil, true},
+		{"http://example.com:8080/nbp", []string{"avg", "both"}, true},
+		{"https://93.184.216.34/nbp", nil, true},
+		{"https://example.com/nbp", []string{"a"}, false},
+		{"ftp://example.com/nbp", nil, false},
+		{"/nbp", nil, false},
+		{"https://:8080/nbp", nil, false},
+		{"https://unknown.test/nbp", nil, false},
+		{"http://localhost:8080/nbp", nil, false},
+		{"http://127.0.0.1/nbp", nil, false},
+		{"http://[::1]/nbp", nil, false},
+		{"http://169.254.169.254/latest/meta-data", nil, false},
+		{"http://internal.test/nbp", nil, false},
+		{"http://mixed.test/nbp", nil, false},
+		{"http://0.0.0.0/nbp", nil, false},
+	}
+	for _, tt := range tests {
+		err := Validate(Hook{URL: tt.url, Types: tt.types})
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%s, %v) = %v, want ok %v", tt.url, tt.types, err, tt.ok)
+		}
+	}
+}